@@ -3,17 +3,49 @@ package router
 import (
 	"context"
 	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
 )
 
-// HTTP request context key
-var contextKey requestContextKey = "request-context"
+// HTTP request context key, zero-sized to be converted to any without allocation
+var contextKey requestContextKey
 
-type requestContextKey string
+// Route parameters stored without growing the pooled slice
+const paramsCapacity = 8
 
-// Context of the request, contains URL parameters
+type requestContextKey struct{}
+
+// Lookup contexts are recycled once the request is served, they are never exposed to handlers
+var contextPool = sync.Pool{
+	New: func() any {
+		return &requestContext{params: make([]routeParam, 0, paramsCapacity)}
+	},
+}
+
+// Context of the request, contains URL parameters and the matched route.
+// It is the request context.Context itself, wrapping the parent one to avoid allocating another context value.
 type requestContext struct {
-	RouteParams map[string]string
-	QueryParams map[string][]string
+	context.Context
+	params      []routeParam
+	rawQuery    string
+	queryOnce   sync.Once
+	query       url.Values    // Parsed on first access
+	MountPath   string        // Path below the prefix of a mounted handler, with escaped slashes when routing on the escaped path
+	Pattern     string        // Pattern of the matched route, including the prefixes of the outer routers
+	RouteName   string        // Name of the matched route, empty if unnamed
+	Method      HttpMethod    // Method of the matched route, MOUNT_METHOD for mounted handlers
+	mountPrefix string        // Pattern prefix stripped from the path given to the mounted handler
+	escapedPath bool          // Routing on the escaped path, slashes and percent signs are escaped until parameters are unescaped
+	request     *http.Request // Request evaluated by the route predicates
+}
+
+// Route parameter captured by a wildcard
+type routeParam struct {
+	name      string
+	value     string
+	converted any // Value produced by the constraint converter, nil otherwise
 }
 
 // Retrieve a parameter value from the request route
@@ -22,8 +54,71 @@ func GetRouteParam(r *http.Request, param string) (string, bool) {
 	if !found {
 		return "", false
 	}
-	val, found := ctx.RouteParams[param]
-	return val, found
+	if p, found := ctx.param(param); found {
+		return p.value, true
+	}
+	return "", false
+}
+
+// Retrieve the converted value of a route parameter using a converter
+func GetRouteParamValue(r *http.Request, param string) (any, bool) {
+	ctx, found := getRequestContext(r)
+	if !found {
+		return nil, false
+	}
+	if p, found := ctx.param(param); found && p.converted != nil {
+		return p.converted, true
+	}
+	return nil, false
+}
+
+// Retrieve the converted value of a route parameter, if it is of type T
+func GetRouteParamAs[T any](r *http.Request, param string) (T, bool) {
+	val, found := GetRouteParamValue(r, param)
+	if !found {
+		var zero T
+		return zero, false
+	}
+	typedVal, ok := val.(T)
+	return typedVal, ok
+}
+
+// Retrieve the value of a route parameter using the "int" converter
+func GetRouteParamInt(r *http.Request, param string) (int64, bool) {
+	return GetRouteParamAs[int64](r, param)
+}
+
+// Retrieve the value of a route parameter using the "date" converter
+func GetRouteParamTime(r *http.Request, param string) (time.Time, bool) {
+	return GetRouteParamAs[time.Time](r, param)
+}
+
+// Retrieve the pattern of the route matching the request, e.g. "/users/{id}"
+func GetRoutePattern(r *http.Request) (string, bool) {
+	ctx, found := getRequestContext(r)
+	if !found {
+		return "", false
+	}
+	return ctx.Pattern, true
+}
+
+// Retrieve the name of the route matching the request, if it is named
+func GetRouteName(r *http.Request) (string, bool) {
+	ctx, found := getRequestContext(r)
+	if !found || ctx.RouteName == "" {
+		return "", false
+	}
+	return ctx.RouteName, true
+}
+
+// Retrieve the method of the route matching the request, which differs from the request one for
+// automatic HEAD responses (GET) and mounted handlers (MOUNT_METHOD)
+func GetRouteMethod(r *http.Request) (HttpMethod, bool) {
+	ctx, found := getRequestContext(r)
+	if !found {
+		return "", false
+	}
+	return ctx.Method, true
 }
 
 // Retrieve the first value of a parameter from the request query
@@ -32,7 +127,7 @@ func GetQueryParam(r *http.Request, param string) (string, bool) {
 	if !found {
 		return "", false
 	}
-	if values, found := ctx.QueryParams[param]; found && len(values) != 0 {
+	if values, found := ctx.queryParams()[param]; found && len(values) != 0 {
 		return values[0], true
 	}
 	return "", false
@@ -44,7 +139,7 @@ func GetQueryParamValues(r *http.Request, param string) ([]string, bool) {
 	if !found {
 		return nil, false
 	}
-	val, found := ctx.QueryParams[param]
+	val, found := ctx.queryParams()[param]
 	return val, found
 }
 
@@ -60,18 +155,107 @@ func GetParam(r *http.Request, param string) (string, bool) {
 }
 
 // Try to extract a requestContext from the given request
-func getRequestContext(r *http.Request) (requestContext, bool) {
-	ctxVal := r.Context().Value(contextKey)
-	var rCtx requestContext
-	if ctxVal == nil {
-		return rCtx, false
+func getRequestContext(r *http.Request) (*requestContext, bool) {
+	rCtx, found := r.Context().Value(contextKey).(*requestContext)
+	return rCtx, found
+}
+
+// Get a context from the pool, wrapping the request one
+func acquireRequestContext(r *http.Request) *requestContext {
+	rCtx := contextPool.Get().(*requestContext)
+	rCtx.Context = r.Context()
+	rCtx.rawQuery = r.URL.RawQuery
+	rCtx.request = r
+	return rCtx
+}
+
+// Reset the context and put it back in the pool, it must not be used afterwards
+func releaseRequestContext(rCtx *requestContext) {
+	clear(rCtx.params)
+	*rCtx = requestContext{params: rCtx.params[:0]}
+	contextPool.Put(rCtx)
+}
+
+// Copy the lookup results to a context owned by the matched request: handlers may keep it
+// after the request is served, e.g. in a goroutine, while the pooled one is recycled
+func (rCtx *requestContext) detach() *requestContext {
+	return &requestContext{
+		Context:     rCtx.Context,
+		params:      slices.Clone(rCtx.params),
+		rawQuery:    rCtx.rawQuery,
+		MountPath:   rCtx.MountPath,
+		escapedPath: rCtx.escapedPath,
+	}
+}
+
+func (rCtx *requestContext) Value(key any) any {
+	if key == contextKey {
+		return rCtx
+	}
+	return rCtx.Context.Value(key)
+}
+
+func (rCtx *requestContext) param(name string) (routeParam, bool) {
+	for _, p := range rCtx.params {
+		if p.name == name {
+			return p, true
+		}
 	}
+	return routeParam{}, false
+}
 
-	return ctxVal.(requestContext), true
+// Remove a parameter, return its value
+func (rCtx *requestContext) removeParam(name string) (string, bool) {
+	for i, p := range rCtx.params {
+		if p.name == name {
+			rCtx.params = slices.Delete(rCtx.params, i, i+1)
+			return p.value, true
+		}
+	}
+	return "", false
 }
 
-// Produce a new request with the given requestContext injected into its context
-func newRequestWithContext(r *http.Request, rCtx requestContext) *http.Request {
-	ctx := context.WithValue(r.Context(), contextKey, rCtx)
-	return r.WithContext(ctx)
+// Query parameters, parsed on first access
+func (rCtx *requestContext) queryParams() url.Values {
+	rCtx.queryOnce.Do(func() {
+		rCtx.query, _ = url.ParseQuery(rCtx.rawQuery)
+	})
+	return rCtx.query
+}
+
+// Add the route parameters of an outer router context, the current ones are favored.
+// The pattern is prefixed by the outer mount prefix when it was stripped from the path.
+func (rCtx *requestContext) inherit(outer *requestContext) {
+	if outer.mountPrefix != "" {
+		rCtx.Pattern = joinPatterns(outer.mountPrefix, rCtx.Pattern)
+		rCtx.mountPrefix = outer.mountPrefix
+	}
+
+	for _, p := range outer.params {
+		if _, found := rCtx.param(p.name); !found {
+			rCtx.params = append(rCtx.params, p)
+		}
+	}
+}
+
+// Populate the net/http request path values and pattern, for handlers using the standard library API
+func setStandardRequestFields(r *http.Request, rCtx *requestContext, matched *route) {
+	for _, p := range rCtx.params {
+		r.SetPathValue(p.name, p.value)
+	}
+
+	pattern := matched.requestPattern
+	if rCtx.Pattern != matched.pattern {
+		// Prefixed by an outer router
+		pattern = standardPattern(rCtx.Method, matched.host, rCtx.Pattern)
+	}
+	setRequestPattern(r, pattern)
+}
+
+// Route pattern in the http.ServeMux format: "[METHOD ][HOST]/path"
+func standardPattern(method HttpMethod, host, pattern string) string {
+	if method == MOUNT_METHOD {
+		return host + pattern
+	}
+	return string(method) + " " + host + pattern
 }
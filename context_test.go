@@ -1,8 +1,11 @@
 package router
 
 import (
+	"context"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestGetRouteParam(t *testing.T) {
@@ -32,10 +35,7 @@ func TestGetRouteParam(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		reqCtx := requestContext{
-			RouteParams: tc.routeParams,
-			QueryParams: tc.queryParams,
-		}
+		reqCtx := newTestRequestContext(tc.routeParams, nil, tc.queryParams)
 		r := buildRequestWithContext(reqCtx)
 
 		result, found := GetRouteParam(r, tc.search)
@@ -48,6 +48,32 @@ func TestGetRouteParam(t *testing.T) {
 	}
 }
 
+func TestGetRouteParamAs(t *testing.T) {
+	date := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	reqCtx := newTestRequestContext(
+		map[string]string{"id": "42", "day": "2024-02-29", "name": "test"},
+		map[string]any{"id": int64(42), "day": date},
+		nil,
+	)
+	r := buildRequestWithContext(reqCtx)
+
+	if id, found := GetRouteParamInt(r, "id"); !found || id != 42 {
+		t.Errorf("unexpected int parameter. expected=%d, got=%d (found=%t)", 42, id, found)
+	}
+	if day, found := GetRouteParamTime(r, "day"); !found || !day.Equal(date) {
+		t.Errorf("unexpected time parameter. expected=%v, got=%v (found=%t)", date, day, found)
+	}
+	if _, found := GetRouteParamInt(r, "day"); found {
+		t.Errorf("parameter of another type shouldn't be found")
+	}
+	if _, found := GetRouteParamAs[string](r, "name"); found {
+		t.Errorf("parameter without converter shouldn't be found")
+	}
+	if val, found := GetRouteParamValue(r, "id"); !found || val != int64(42) {
+		t.Errorf("unexpected parameter value. expected=%v, got=%v (found=%t)", int64(42), val, found)
+	}
+}
+
 func TestGetQueryParam(t *testing.T) {
 	testCases := []struct {
 		routeParams map[string]string
@@ -83,10 +109,7 @@ func TestGetQueryParam(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		reqCtx := requestContext{
-			RouteParams: tc.routeParams,
-			QueryParams: tc.queryParams,
-		}
+		reqCtx := newTestRequestContext(tc.routeParams, nil, tc.queryParams)
 		r := buildRequestWithContext(reqCtx)
 
 		result, found := GetQueryParam(r, tc.search)
@@ -134,10 +157,7 @@ func TestGetQueryParamValues(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		reqCtx := requestContext{
-			RouteParams: tc.routeParams,
-			QueryParams: tc.queryParams,
-		}
+		reqCtx := newTestRequestContext(tc.routeParams, nil, tc.queryParams)
 		r := buildRequestWithContext(reqCtx)
 
 		result, found := GetQueryParamValues(r, tc.search)
@@ -199,10 +219,7 @@ func TestGetParam(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		reqCtx := requestContext{
-			RouteParams: tc.routeParams,
-			QueryParams: tc.queryParams,
-		}
+		reqCtx := newTestRequestContext(tc.routeParams, nil, tc.queryParams)
 		r := buildRequestWithContext(reqCtx)
 
 		result, found := GetParam(r, tc.search)
@@ -215,7 +232,15 @@ func TestGetParam(t *testing.T) {
 	}
 }
 
-func buildRequestWithContext(rCtx requestContext) *http.Request {
+func newTestRequestContext(routeParams map[string]string, typedParams map[string]any, queryParams map[string][]string) *requestContext {
+	rCtx := &requestContext{Context: context.Background(), rawQuery: url.Values(queryParams).Encode()}
+	for name, value := range routeParams {
+		rCtx.params = append(rCtx.params, routeParam{name, value, typedParams[name]})
+	}
+	return rCtx
+}
+
+func buildRequestWithContext(rCtx *requestContext) *http.Request {
 	r := http.Request{}
-	return newRequestWithContext(&r, rCtx)
+	return r.WithContext(rCtx)
 }
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Layout of the "date" converter values
+const DATE_LAYOUT string = "2006-01-02"
+
+var (
+	uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	slugRegexp = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+)
+
+// Validate and convert a route parameter value. An error means that the value doesn't match the route.
+type ParamConverter func(value string) (any, error)
+
+// Converters available by default, usable with the "{name:converter}" syntax
+func defaultConverters() map[string]ParamConverter {
+	return map[string]ParamConverter{
+		"int":  convertInt,
+		"uuid": convertUuid,
+		"slug": convertSlug,
+		"date": convertDate,
+	}
+}
+
+// Convert to an int64
+func convertInt(value string) (any, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Validate a UUID in its canonical textual form, the value is kept as string
+func convertUuid(value string) (any, error) {
+	if !uuidRegexp.MatchString(value) {
+		return nil, errors.New("invalid uuid")
+	}
+	return value, nil
+}
+
+// Validate a lowercase, hyphen separated slug, the value is kept as string
+func convertSlug(value string) (any, error) {
+	if !slugRegexp.MatchString(value) {
+		return nil, errors.New("invalid slug")
+	}
+	return value, nil
+}
+
+// Convert a DATE_LAYOUT formatted date to a time.Time
+func convertDate(value string) (any, error) {
+	return time.Parse(DATE_LAYOUT, value)
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/valsov/router/middleware"
+)
+
+// Set of routes sharing a pattern prefix and middleware, and optionally a host pattern
+type RouteGroup struct {
+	router          *HttpRouter
+	host            string
+	prefix          string
+	middlewareChain []middleware.Middleware // Outer groups middleware first
+}
+
+// Create a group of routes prefixed by the given pattern, the group middleware only runs for these routes,
+// after the router middleware
+func (r *HttpRouter) Group(prefix string, middleware ...middleware.Middleware) *RouteGroup {
+	return &RouteGroup{
+		router:          r,
+		prefix:          prefix,
+		middlewareChain: slices.Clone(middleware),
+	}
+}
+
+// Create a group of routes only matching the requests to the host, see WithHost.
+// Its routes are favored over the routes matching every host.
+func (r *HttpRouter) Host(host string, middleware ...middleware.Middleware) *RouteGroup {
+	return &RouteGroup{
+		router:          r,
+		host:            host,
+		middlewareChain: slices.Clone(middleware),
+	}
+}
+
+// Create a nested group, its prefix and middleware are appended to the current group ones
+func (g *RouteGroup) Group(prefix string, middleware ...middleware.Middleware) *RouteGroup {
+	return &RouteGroup{
+		router:          g.router,
+		host:            g.host,
+		prefix:          joinPatterns(g.prefix, prefix),
+		middlewareChain: append(slices.Clone(g.middlewareChain), middleware...),
+	}
+}
+
+// Can panic, see TryHandle
+func (g *RouteGroup) Handle(method HttpMethod, route string, handler http.Handler, opts ...RouteOption) *RouteGroup {
+	if err := g.TryHandle(method, route, handler, opts...); err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Can panic, see TryHandleFunc
+func (g *RouteGroup) HandleFunc(method HttpMethod, route string, handler http.HandlerFunc, opts ...RouteOption) *RouteGroup {
+	return g.Handle(method, route, handler, opts...)
+}
+
+// Register the handler for the prefixed route, errors are of type *RegistrationError
+func (g *RouteGroup) TryHandle(method HttpMethod, route string, handler http.Handler, opts ...RouteOption) error {
+	// Group middleware runs before the route one
+	opts = append([]RouteOption{WithHost(g.host), WithMiddleware(g.middlewareChain...)}, opts...)
+	return g.router.TryHandle(method, joinPatterns(g.prefix, route), handler, opts...)
+}
+
+// Register the handler function for the prefixed route, errors are of type *RegistrationError
+func (g *RouteGroup) TryHandleFunc(method HttpMethod, route string, handler http.HandlerFunc, opts ...RouteOption) error {
+	return g.TryHandle(method, route, handler, opts...)
+}
+
+// Can panic, see TryMount
+func (g *RouteGroup) Mount(prefix string, handler http.Handler, opts ...RouteOption) *RouteGroup {
+	if err := g.TryMount(prefix, handler, opts...); err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Mount the handler under the group prefix joined with the given one, see HttpRouter.TryMount
+func (g *RouteGroup) TryMount(prefix string, handler http.Handler, opts ...RouteOption) error {
+	// Group middleware runs before the route one
+	opts = append([]RouteOption{WithHost(g.host), WithMiddleware(g.middlewareChain...)}, opts...)
+	return g.router.TryMount(joinPatterns(g.prefix, prefix), handler, opts...)
+}
+
+// Join two route patterns with a single '/'
+func joinPatterns(prefix, route string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(route, "/")
+}
@@ -0,0 +1,168 @@
+package router
+
+import (
+	"errors"
+	"strings"
+)
+
+// Host pattern with wildcard labels, e.g. "{tenant}.example.com"
+type hostPattern struct {
+	pattern string      // Normalized pattern, identifying it
+	labels  []hostLabel // Dot separated labels, in host order
+	statics int         // Number of static labels
+	tree    *tree       // Routes of the matching hosts
+}
+
+// Static label or wildcard spanning a whole label
+type hostLabel struct {
+	literal    string // Lowercased static label
+	name       string // Wildcard name, empty for static labels
+	constraint *paramConstraint
+}
+
+// Parse a host pattern, the port is ignored and static labels are compared case-insensitively.
+// Returns a *SyntaxError if it is invalid.
+func (t *tree) parseHostPattern(pattern string) (*hostPattern, error) {
+	host := &hostPattern{}
+	names := make(map[string]struct{})
+	normalized := make([]string, 0, strings.Count(pattern, ".")+1)
+	offset := 0
+	for _, label := range splitHostLabels(strings.TrimSuffix(stripPort(pattern), ".")) {
+		if label == "" {
+			return nil, &SyntaxError{Pattern: pattern, Offset: offset, Msg: "empty host label"}
+		}
+
+		if strings.IndexByte(label, WILDCARD_START_CHAR) == -1 {
+			host.labels = append(host.labels, hostLabel{literal: strings.ToLower(label)})
+			host.statics++
+			normalized = append(normalized, strings.ToLower(label))
+			offset += len(label) + 1
+			continue
+		}
+
+		// Wildcards use the route patterns syntax
+		parsed, err := ParsePattern("/" + label)
+		if err != nil {
+			return nil, labelSyntaxError(pattern, offset, err)
+		}
+		param, isParam := parsed.Segments[0].Nodes[0].(*Param)
+		if len(parsed.Segments) != 1 || len(parsed.Segments[0].Nodes) != 1 || !isParam {
+			return nil, &SyntaxError{Pattern: pattern, Offset: offset, Msg: "host wildcards must span a whole label"}
+		}
+		if param.Optional {
+			return nil, &SyntaxError{Pattern: pattern, Offset: offset, Msg: "host wildcards can't be optional"}
+		}
+		if _, found := names[param.Name]; found {
+			return nil, &SyntaxError{Pattern: pattern, Offset: offset, Msg: "duplicated wildcard parameter name: " + param.Name}
+		}
+		names[param.Name] = struct{}{}
+
+		constraint, err := t.resolveConstraint(parsed, param.Constraint)
+		if err != nil {
+			return nil, labelSyntaxError(pattern, offset, err)
+		}
+		host.labels = append(host.labels, hostLabel{name: param.Name, constraint: constraint})
+		normalized = append(normalized, label)
+		offset += len(label) + 1
+	}
+	host.pattern = strings.Join(normalized, ".")
+	return host, nil
+}
+
+// Empty tree for the routes of a host, sharing the converters of t
+func (t *tree) newHostTree() *tree {
+	hostTree := NewTree()
+	hostTree.converters = t.converters
+	return hostTree
+}
+
+// Locate the syntax error of a label, parsed as a "/label" route pattern, in the host pattern
+func labelSyntaxError(pattern string, labelOffset int, err error) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &SyntaxError{Pattern: pattern, Offset: labelOffset + syntaxErr.Offset - 1, Msg: syntaxErr.Msg}
+	}
+	return err
+}
+
+// Hosts without wildcards are matched by name
+func (h *hostPattern) isExact() bool {
+	return h.statics == len(h.labels)
+}
+
+// Names of the host wildcards, in host order
+func (h *hostPattern) paramNames() []string {
+	var names []string
+	for _, label := range h.labels {
+		if label.name != "" {
+			names = append(names, label.name)
+		}
+	}
+	return names
+}
+
+// Match the normalized host name, the captured parameters are appended to params only when it matches
+func (h *hostPattern) match(host string, params *[]routeParam) bool {
+	length := len(*params)
+	for i, label := range h.labels {
+		end := strings.IndexByte(host, '.')
+		if (end == -1) != (i == len(h.labels)-1) {
+			// Labels count mismatch
+			*params = (*params)[:length]
+			return false
+		}
+		if end == -1 {
+			end = len(host)
+		}
+
+		value := host[:end]
+		if label.name == "" {
+			if value != label.literal {
+				*params = (*params)[:length]
+				return false
+			}
+		} else {
+			converted, match := label.constraint.match(value)
+			if value == "" || !match {
+				*params = (*params)[:length]
+				return false
+			}
+			*params = append(*params, routeParam{label.name, value, converted})
+		}
+		host = host[min(end+1, len(host)):]
+	}
+	return true
+}
+
+// Split the host pattern on the dots outside of wildcards, whose constraint may contain dots
+func splitHostLabels(pattern string) []string {
+	var labels []string
+	depth, start := 0, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case WILDCARD_START_CHAR:
+			depth++
+		case WILDCARD_END_CHAR:
+			depth--
+		case '.':
+			if depth == 0 {
+				labels = append(labels, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(labels, pattern[start:])
+}
+
+// Host name of a request, without port nor trailing dot, lowercased. Doesn't allocate when it is already lowercase.
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(stripPort(host), "."))
+}
+
+// Remove the port of a host, IPv6 addresses are enclosed in brackets and wildcards may contain colons
+func stripPort(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i != -1 && i > strings.LastIndexAny(host, "]}") {
+		return host[:i]
+	}
+	return host
+}
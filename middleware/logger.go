@@ -5,6 +5,8 @@ import (
 	"time"
 )
 
+// Request logger. The request holds the matched route data, such as the route pattern (router.GetRoutePattern)
+// which is a better label than the raw path for metrics.
 type RequestLogger interface {
 	LogRequest(req *http.Request, elapsed time.Duration)
 }
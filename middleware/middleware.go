@@ -1,6 +1,11 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
 
 // Middleware function, accepting a next http.Handler as parameter
 type Middleware func(http.Handler) http.Handler
@@ -18,3 +23,39 @@ func GetHandlerChain(handler http.Handler, middleware []Middleware) http.Handler
 
 	return currentHandler
 }
+
+// Describe the middleware with the name of the function producing it, e.g. "middleware.LoggerMiddleware"
+func Name(middleware Middleware) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(middleware).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+
+	name := fn.Name()
+	// Remove the package path directories
+	if index := strings.LastIndexByte(name, '/'); index != -1 {
+		name = name[index+1:]
+	}
+	// Remove the closures suffixes: "LoggerMiddleware.func1.2" -> "LoggerMiddleware"
+	for {
+		index := strings.LastIndexByte(name, '.')
+		if index == -1 || !isClosureSuffix(name[index+1:]) {
+			break
+		}
+		name = name[:index]
+	}
+	return name
+}
+
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
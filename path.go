@@ -0,0 +1,104 @@
+package router
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Handling of the request paths which don't match a route as is
+type PathPolicy int
+
+const (
+	// Only serve the routes matching the path as is
+	PATH_STRICT PathPolicy = iota
+	// Serve the route matching the normalized path
+	PATH_LENIENT
+	// Redirect to the normalized path when it matches a route: 301 for GET requests, 308 otherwise
+	PATH_REDIRECT
+)
+
+// Resolve "." and ".." segments and remove duplicate slashes, like path.Clean but keeping the trailing slash.
+// Doesn't allocate when the path is already clean.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	cleaned := path.Clean(p)
+	if p[len(p)-1] == '/' && cleaned != "/" {
+		if len(p) == len(cleaned)+1 && strings.HasPrefix(p, cleaned) {
+			return p
+		}
+		return cleaned + "/"
+	}
+	return cleaned
+}
+
+// Path with a trailing slash added or removed, false for the root path
+func toggleTrailingSlash(p string) (string, bool) {
+	if p == "/" {
+		return "", false
+	}
+	if trimmed, found := strings.CutSuffix(p, "/"); found {
+		return trimmed, true
+	}
+	return p + "/", true
+}
+
+// Path routed on when using the escaped path: escaped characters are decoded, except slashes and percent signs
+// which keep their escaped form so that static route parts compare to decoded characters while parameters are
+// unescaped individually. Doesn't allocate when there is nothing to decode.
+func routingPath(escaped string) string {
+	var decoded []byte
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' || i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			if decoded != nil {
+				decoded = append(decoded, escaped[i])
+			}
+			continue
+		}
+
+		c := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+		if c == '/' || c == '%' {
+			if decoded != nil {
+				decoded = append(decoded, escaped[i:i+3]...)
+			}
+		} else {
+			if decoded == nil {
+				decoded = append(make([]byte, 0, len(escaped)), escaped[:i]...)
+			}
+			decoded = append(decoded, c)
+		}
+		i += 2
+	}
+
+	if decoded == nil {
+		return escaped
+	}
+	return string(decoded)
+}
+
+// Escaped form of a routing path, for URL.RawPath
+func escapeRoutingPath(p string) string {
+	// Percent signs of the routing path are part of the escaped slashes and percent signs
+	return strings.ReplaceAll((&url.URL{Path: p}).EscapedPath(), "%25", "%")
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
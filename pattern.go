@@ -0,0 +1,316 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verify interface compliance
+var (
+	_ PatternNode = &Literal{}
+	_ PatternNode = &Param{}
+	_ PatternNode = &CatchAll{}
+)
+
+// Parsed route pattern
+type Pattern struct {
+	Raw           string
+	Segments      []Segment // Non-empty path segments, in order
+	TrailingSlash bool      // The last segment is followed by a '/', which is ignored after a catch-all
+}
+
+// Path segment of a route pattern, made of a single catch-all, or of literals and params
+type Segment struct {
+	Offset int    // Position of the segment in the pattern
+	Raw    string // Segment as written in the pattern
+	Nodes  []PatternNode
+}
+
+// Node of a pattern segment: *Literal, *Param or *CatchAll
+type PatternNode interface {
+	// Position of the node in the pattern
+	Position() int
+}
+
+// Static part of a segment
+type Literal struct {
+	Offset int
+	Value  string
+}
+
+// Wildcard matching a segment or a part of it: "{name}", "{name:constraint}", "{name?}"
+type Param struct {
+	Offset     int
+	Name       string
+	Constraint *Constraint // Nil when unconstrained
+	Optional   bool        // Can be omitted, only in trailing segments
+}
+
+// Wildcard matching the remainder of the path: "{name...}", "{name:constraint...}", "{name?...}" or "*name"
+type CatchAll struct {
+	Offset     int
+	Name       string
+	Constraint *Constraint // Nil when unconstrained
+	Optional   bool        // Can be omitted
+}
+
+// Wildcard constraint: a named converter or a regular expression
+type Constraint struct {
+	Offset int
+	Expr   string
+}
+
+// Route pattern syntax error, located in the pattern
+type SyntaxError struct {
+	Pattern string
+	Offset  int // Byte offset of the error in the pattern
+	Msg     string
+}
+
+func (l *Literal) Position() int  { return l.Offset }
+func (p *Param) Position() int    { return p.Offset }
+func (c *CatchAll) Position() int { return c.Offset }
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid route pattern %q at offset %d: %s", e.Pattern, e.Offset, e.Msg)
+}
+
+// Match ErrInvalidPattern with errors.Is
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrInvalidPattern
+}
+
+// Parse a route pattern, returns a *SyntaxError if it is invalid.
+// Constraints are not compiled: they may reference converters only known by the router.
+func ParsePattern(pattern string) (*Pattern, error) {
+	parser := patternParser{pattern: pattern}
+	return parser.parse()
+}
+
+// Names of the pattern wildcards, in order
+func (p *Pattern) ParamNames() []string {
+	var names []string
+	for _, segment := range p.Segments {
+		for _, node := range segment.Nodes {
+			switch n := node.(type) {
+			case *Param:
+				names = append(names, n.Name)
+			case *CatchAll:
+				names = append(names, n.Name)
+			}
+		}
+	}
+	return names
+}
+
+type patternParser struct {
+	pattern string
+}
+
+func (p *patternParser) errorf(offset int, format string, args ...any) *SyntaxError {
+	return &SyntaxError{Pattern: p.pattern, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *patternParser) parse() (*Pattern, error) {
+	result := &Pattern{Raw: p.pattern}
+	for offset := 0; offset < len(p.pattern); {
+		if p.pattern[offset] == '/' {
+			// Empty segments are ignored
+			offset++
+			continue
+		}
+
+		end := p.segmentEnd(offset)
+		segment, err := p.parseSegment(offset, end)
+		if err != nil {
+			return nil, err
+		}
+		result.Segments = append(result.Segments, segment)
+		offset = end
+	}
+
+	if err := p.validate(result); err != nil {
+		return nil, err
+	}
+	if len(result.Segments) != 0 && strings.HasSuffix(p.pattern, "/") {
+		_, isCatchAll := result.Segments[len(result.Segments)-1].Nodes[0].(*CatchAll)
+		result.TrailingSlash = !isCatchAll
+	}
+	return result, nil
+}
+
+// Index of the '/' ending the segment starting at offset, or the pattern length. Slashes in wildcards are ignored.
+func (p *patternParser) segmentEnd(offset int) int {
+	depth := 0
+	for i := offset; i < len(p.pattern); i++ {
+		switch p.pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth != 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.pattern)
+}
+
+func (p *patternParser) parseSegment(start, end int) (Segment, error) {
+	segment := Segment{Offset: start, Raw: p.pattern[start:end]}
+	if p.pattern[start] == CATCH_ALL_START_CHAR {
+		// Handle "*name" catch-all
+		if err := p.validateName(start+1, p.pattern[start+1:end]); err != nil {
+			return Segment{}, err
+		}
+		segment.Nodes = []PatternNode{&CatchAll{Offset: start, Name: p.pattern[start+1 : end]}}
+		return segment, nil
+	}
+
+	for i := start; i < end; {
+		switch p.pattern[i] {
+		case WILDCARD_START_CHAR:
+			closing := p.closingBraceIndex(i, end)
+			if closing == -1 {
+				return Segment{}, p.errorf(i, "unclosed wildcard parameter")
+			}
+			if len(segment.Nodes) != 0 {
+				if _, isLiteral := segment.Nodes[len(segment.Nodes)-1].(*Literal); !isLiteral {
+					return Segment{}, p.errorf(i, "adjacent wildcard parameters")
+				}
+			}
+
+			node, err := p.parseWildcard(i, closing)
+			if err != nil {
+				return Segment{}, err
+			}
+			segment.Nodes = append(segment.Nodes, node)
+			i = closing + 1
+		case WILDCARD_END_CHAR:
+			return Segment{}, p.errorf(i, "unexpected '%c'", WILDCARD_END_CHAR)
+		default:
+			literalEnd := i
+			for literalEnd < end && p.pattern[literalEnd] != WILDCARD_START_CHAR && p.pattern[literalEnd] != WILDCARD_END_CHAR {
+				literalEnd++
+			}
+			segment.Nodes = append(segment.Nodes, &Literal{Offset: i, Value: p.pattern[i:literalEnd]})
+			i = literalEnd
+		}
+	}
+
+	if len(segment.Nodes) > 1 {
+		// Partial-segment wildcards
+		for _, node := range segment.Nodes {
+			switch n := node.(type) {
+			case *CatchAll:
+				return Segment{}, p.errorf(n.Offset, "catch-all parameter must span the whole route segment")
+			case *Param:
+				if n.Optional {
+					return Segment{}, p.errorf(n.Offset, "optional parameter must span the whole route segment")
+				}
+			}
+		}
+	}
+	return segment, nil
+}
+
+// Index of the brace closing the one at offset, braces of constraints are balanced. -1 if not found before end.
+func (p *patternParser) closingBraceIndex(offset, end int) int {
+	depth := 0
+	for i := offset; i < end; i++ {
+		switch p.pattern[i] {
+		case WILDCARD_START_CHAR:
+			depth++
+		case WILDCARD_END_CHAR:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Parse the wildcard enclosed in the braces at start and end: "name", "name:constraint" or "name...",
+// where the name can be suffixed by '?' to make it optional
+func (p *patternParser) parseWildcard(start, end int) (PatternNode, error) {
+	definition := p.pattern[start+1 : end]
+	definition, catchAll := strings.CutSuffix(definition, CATCH_ALL_SUFFIX)
+
+	name := definition
+	var constraint *Constraint
+	if sepIndex := strings.IndexByte(definition, CONSTRAINT_SEPARATOR); sepIndex != -1 {
+		name = definition[:sepIndex]
+		constraint = &Constraint{Offset: start + 1 + sepIndex + 1, Expr: definition[sepIndex+1:]}
+		if constraint.Expr == "" {
+			return nil, p.errorf(constraint.Offset, "empty wildcard constraint")
+		}
+	}
+
+	name, optional := strings.CutSuffix(name, OPTIONAL_SUFFIX)
+	if err := p.validateName(start+1, name); err != nil {
+		return nil, err
+	}
+
+	if catchAll {
+		return &CatchAll{Offset: start, Name: name, Constraint: constraint, Optional: optional}, nil
+	}
+	return &Param{Offset: start, Name: name, Constraint: constraint, Optional: optional}, nil
+}
+
+// Wildcard names are made of letters, digits, '_' and '-'
+func (p *patternParser) validateName(offset int, name string) error {
+	if name == "" {
+		return p.errorf(offset, "wildcard parameter without name")
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			return p.errorf(offset+i, "invalid character '%c' in wildcard parameter name", c)
+		}
+	}
+	return nil
+}
+
+// Check the rules spanning several segments
+func (p *patternParser) validate(pattern *Pattern) error {
+	names := make(map[string]struct{})
+	optionalFound := false
+	for i, segment := range pattern.Segments {
+		for _, node := range segment.Nodes {
+			var name string
+			var optional bool
+			switch n := node.(type) {
+			case *Literal:
+				continue
+			case *Param:
+				name, optional = n.Name, n.Optional
+			case *CatchAll:
+				name, optional = n.Name, n.Optional
+				if i != len(pattern.Segments)-1 {
+					return p.errorf(n.Offset, "catch-all parameter must be the last route segment")
+				}
+			}
+
+			if _, found := names[name]; found {
+				return p.errorf(node.Position(), "duplicated wildcard parameter name: %s", name)
+			}
+			names[name] = struct{}{}
+
+			if optional {
+				optionalFound = true
+			} else if optionalFound {
+				return p.errorf(segment.Offset, "optional parameters must be the last route segments")
+			}
+		}
+		if optionalFound && len(segment.Nodes) == 1 {
+			if _, isLiteral := segment.Nodes[0].(*Literal); isLiteral {
+				return p.errorf(segment.Offset, "optional parameters must be the last route segments")
+			}
+		}
+	}
+	return nil
+}
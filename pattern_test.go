@@ -0,0 +1,132 @@
+package router
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	testCases := []struct {
+		pattern        string
+		expected       []Segment
+		expectedOffset int // Syntax error offset, -1 when valid
+	}{
+		{
+			pattern:        "/",
+			expected:       nil,
+			expectedOffset: -1,
+		},
+		{
+			pattern: "/users//{id:[0-9]{2}}/",
+			expected: []Segment{
+				{Offset: 1, Raw: "users", Nodes: []PatternNode{&Literal{Offset: 1, Value: "users"}}},
+				{Offset: 8, Raw: "{id:[0-9]{2}}", Nodes: []PatternNode{&Param{Offset: 8, Name: "id", Constraint: &Constraint{Offset: 12, Expr: "[0-9]{2}"}}}},
+			},
+			expectedOffset: -1,
+		},
+		{
+			pattern: "/v{version}/{name}.{ext:[a-z/]+}",
+			expected: []Segment{
+				{Offset: 1, Raw: "v{version}", Nodes: []PatternNode{
+					&Literal{Offset: 1, Value: "v"},
+					&Param{Offset: 2, Name: "version"},
+				}},
+				{Offset: 12, Raw: "{name}.{ext:[a-z/]+}", Nodes: []PatternNode{
+					&Param{Offset: 12, Name: "name"},
+					&Literal{Offset: 18, Value: "."},
+					&Param{Offset: 19, Name: "ext", Constraint: &Constraint{Offset: 24, Expr: "[a-z/]+"}},
+				}},
+			},
+			expectedOffset: -1,
+		},
+		{
+			pattern: "/reports/{year?:int}/{path?...}",
+			expected: []Segment{
+				{Offset: 1, Raw: "reports", Nodes: []PatternNode{&Literal{Offset: 1, Value: "reports"}}},
+				{Offset: 9, Raw: "{year?:int}", Nodes: []PatternNode{&Param{Offset: 9, Name: "year", Constraint: &Constraint{Offset: 16, Expr: "int"}, Optional: true}}},
+				{Offset: 21, Raw: "{path?...}", Nodes: []PatternNode{&CatchAll{Offset: 21, Name: "path", Optional: true}}},
+			},
+			expectedOffset: -1,
+		},
+		{
+			pattern: "/static/*filepath",
+			expected: []Segment{
+				{Offset: 1, Raw: "static", Nodes: []PatternNode{&Literal{Offset: 1, Value: "static"}}},
+				{Offset: 8, Raw: "*filepath", Nodes: []PatternNode{&CatchAll{Offset: 8, Name: "filepath"}}},
+			},
+			expectedOffset: -1,
+		},
+		{
+			pattern:        "/users/{abc",
+			expectedOffset: 7, // Unclosed wildcard
+		},
+		{
+			pattern:        "/users/{}",
+			expectedOffset: 8, // Missing name
+		},
+		{
+			pattern:        "/users/{a}{b}",
+			expectedOffset: 10, // Adjacent wildcards
+		},
+		{
+			pattern:        "/users/a}",
+			expectedOffset: 8, // Unexpected closing brace
+		},
+		{
+			pattern:        "/users/{a b}",
+			expectedOffset: 9, // Invalid name character
+		},
+		{
+			pattern:        "/users/{id:}",
+			expectedOffset: 11, // Empty constraint
+		},
+		{
+			pattern:        "/users/*",
+			expectedOffset: 8, // Missing catch-all name
+		},
+		{
+			pattern:        "/files/{path...}/test",
+			expectedOffset: 7, // Catch-all not in last segment
+		},
+		{
+			pattern:        "/files/a{path...}",
+			expectedOffset: 8, // Catch-all not spanning the whole segment
+		},
+		{
+			pattern:        "/files/{a?}/{b}",
+			expectedOffset: 12, // Optional parameter not trailing
+		},
+		{
+			pattern:        "/files/{a?}/b",
+			expectedOffset: 12, // Optional parameter not trailing
+		},
+		{
+			pattern:        "/files/{a?}.txt",
+			expectedOffset: 7, // Optional parameter not spanning the whole segment
+		},
+		{
+			pattern:        "/{id}/{id:int}",
+			expectedOffset: 6, // Duplicated name
+		},
+	}
+
+	for _, tc := range testCases {
+		pattern, err := ParsePattern(tc.pattern)
+		if tc.expectedOffset != -1 {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("%s expected syntax error, got=%v", tc.pattern, err)
+			} else if syntaxErr.Offset != tc.expectedOffset {
+				t.Errorf("%s got unexpected error offset. expected=%d, got=%d (%v)", tc.pattern, tc.expectedOffset, syntaxErr.Offset, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s unexpected error: %v", tc.pattern, err)
+		} else if !reflect.DeepEqual(tc.expected, pattern.Segments) {
+			t.Errorf("%s got unexpected segments. expected=%#v, got=%#v", tc.pattern, tc.expected, pattern.Segments)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Request condition of a route. Routes registered for the same method and path are distinguished by their predicates,
+// routes having the same set of predicates conflict.
+type Predicate interface {
+	Match(r *http.Request) bool
+	String() string // Description identifying the predicate
+}
+
+// Handler of a route registered with predicates
+type predicatedHandler struct {
+	Predicates []Predicate
+	Handler    http.Handler
+}
+
+// Check that the request matches all the predicates. Without request, predicates are not evaluated.
+func (p *predicatedHandler) match(r *http.Request) bool {
+	if r == nil {
+		return true
+	}
+	for _, predicate := range p.Predicates {
+		if !predicate.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sorted predicates descriptions, identifying a set of predicates
+func predicatesKey(predicates []Predicate) string {
+	descriptions := make([]string, len(predicates))
+	for i, predicate := range predicates {
+		descriptions[i] = predicate.String()
+	}
+	slices.Sort(descriptions)
+	return strings.Join(descriptions, ", ")
+}
+
+type headerPredicate struct {
+	name  string
+	value string
+}
+
+// Match the requests having the header with the given value
+func MatchHeader(name, value string) Predicate {
+	return &headerPredicate{http.CanonicalHeaderKey(name), value}
+}
+
+func (p *headerPredicate) Match(r *http.Request) bool {
+	return slices.Contains(r.Header.Values(p.name), p.value)
+}
+
+func (p *headerPredicate) String() string {
+	return "header " + p.name + "=" + p.value
+}
+
+type acceptPredicate struct {
+	mediaType string
+}
+
+// Match the requests listing the media type in their Accept header, e.g. "application/vnd.api.v2+json".
+// Media ranges such as "*/*" don't match.
+func MatchAccept(mediaType string) Predicate {
+	return &acceptPredicate{strings.ToLower(mediaType)}
+}
+
+func (p *acceptPredicate) Match(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for header != "" {
+			var accepted string
+			accepted, header, _ = strings.Cut(header, ",")
+			mediaType, _, _ := strings.Cut(accepted, ";") // Ignore parameters, such as the quality
+			if strings.EqualFold(strings.TrimSpace(mediaType), p.mediaType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *acceptPredicate) String() string {
+	return "accept " + p.mediaType
+}
+
+type queryPredicate struct {
+	key string
+}
+
+// Match the requests having the query parameter, with any value
+func MatchQuery(key string) Predicate {
+	return &queryPredicate{key}
+}
+
+func (p *queryPredicate) Match(r *http.Request) bool {
+	query := r.URL.RawQuery
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		key, _, _ := strings.Cut(pair, "=")
+		if strings.ContainsAny(key, "%+") {
+			var err error
+			if key, err = url.QueryUnescape(key); err != nil {
+				continue
+			}
+		}
+		if key == p.key {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryPredicate) String() string {
+	return "query " + p.key
+}
+
+type funcPredicate struct {
+	description string
+	match       func(*http.Request) bool
+}
+
+// Match the requests accepted by the function, the description identifies the predicate
+func MatchFunc(description string, match func(*http.Request) bool) Predicate {
+	return &funcPredicate{description, match}
+}
+
+func (p *funcPredicate) Match(r *http.Request) bool {
+	return p.match(r)
+}
+
+func (p *funcPredicate) String() string {
+	return p.description
+}
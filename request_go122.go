@@ -0,0 +1,8 @@
+//go:build !go1.23
+
+package router
+
+import "net/http"
+
+// Request.Pattern is not available before Go 1.23
+func setRequestPattern(r *http.Request, pattern string) {}
@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package router
+
+import "net/http"
+
+// Set Request.Pattern, introduced in Go 1.23
+func setRequestPattern(r *http.Request, pattern string) {
+	r.Pattern = pattern
+}
@@ -0,0 +1,55 @@
+//go:build go1.23
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestPattern(t *testing.T) {
+	testCases := []struct {
+		route           string
+		expectedPattern string
+	}{
+		{
+			route:           "/users/42",
+			expectedPattern: "GET /users/{id}",
+		},
+		{
+			route:           "/tenants/acme/users/42",
+			expectedPattern: "GET /tenants/{tenant}/users/{id}",
+		},
+		{
+			route:           "/legacy/a",
+			expectedPattern: "/legacy",
+		},
+		{
+			route:           "http://api.example.com/status",
+			expectedPattern: "GET api.example.com/status",
+		},
+	}
+
+	var pattern string
+	patternHandler := func(w http.ResponseWriter, r *http.Request) {
+		pattern = r.Pattern
+	}
+	tenantRouter := NewHttpRouter()
+	tenantRouter.HandleFunc(GET, "/users/{id}", patternHandler)
+
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users/{id}", patternHandler)
+	mux.Mount("/tenants/{tenant}", tenantRouter, WithStripPrefix())
+	mux.Mount("/legacy", http.HandlerFunc(patternHandler))
+	mux.HandleFunc(GET, "/status", patternHandler, WithHost("api.example.com"))
+
+	for _, tc := range testCases {
+		pattern = ""
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.route, nil))
+
+		if pattern != tc.expectedPattern {
+			t.Errorf("%s got unexpected pattern. expected=%q, got=%q", tc.route, tc.expectedPattern, pattern)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"io"
+	"net/http"
+)
+
+// Verify interface compliance
+var _ io.StringWriter = &headResponseWriter{}
+
+// http.ResponseWriter discarding the response body, used to answer HEAD requests with GET handlers
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *headResponseWriter) WriteString(s string) (int, error) {
+	return len(s), nil
+}
+
+// Expose the wrapped http.ResponseWriter to http.ResponseController
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,190 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrUnknownRouteName error = errors.New("no route registered with this name")
+	ErrMissingParam     error = errors.New("missing route parameter")
+	ErrInvalidParam     error = errors.New("route parameter doesn't satisfy its constraint")
+)
+
+// Pattern of a named route, with its resolved constraints
+type urlTemplate struct {
+	pattern     *Pattern
+	host        *hostPattern                // Set for the routes restricted to a host
+	constraints map[string]*paramConstraint // Constraints by wildcard name
+}
+
+func (t *tree) newUrlTemplate(route string) (*urlTemplate, error) {
+	pattern, err := ParsePattern(route)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &urlTemplate{pattern: pattern, constraints: make(map[string]*paramConstraint)}
+	for _, segment := range pattern.Segments {
+		for _, node := range segment.Nodes {
+			var name string
+			var constraint *Constraint
+			switch n := node.(type) {
+			case *Param:
+				name, constraint = n.Name, n.Constraint
+			case *CatchAll:
+				name, constraint = n.Name, n.Constraint
+			}
+			if constraint == nil {
+				continue
+			}
+
+			compiled, err := t.resolveConstraint(pattern, constraint)
+			if err != nil {
+				return nil, err
+			}
+			template.constraints[name] = compiled
+		}
+	}
+	return template, nil
+}
+
+// Restrict the template to the host pattern, its wildcards values are then required to build URLs
+func (tmpl *urlTemplate) setHost(host *hostPattern) {
+	tmpl.host = host
+	for _, label := range host.labels {
+		if label.name != "" {
+			tmpl.constraints[label.name] = label.constraint
+		}
+	}
+}
+
+// Build the URL of a named route. Params are key-value pairs: values of the route wildcards are escaped and checked
+// against their constraint, other pairs are appended as query values.
+// Routes restricted to a host get a scheme-relative URL, such as "//acme.example.com/dashboard".
+func (r *HttpRouter) URL(name string, params ...string) (string, error) {
+	registered, found := r.namedRoutes[name]
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrUnknownRouteName, name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("odd count of route parameters, expected key-value pairs: %v", params)
+	}
+
+	values := make(url.Values, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values.Add(params[i], params[i+1])
+	}
+	return registered.urlTemplate.build(values)
+}
+
+// Build the URL path with the values of the wildcards, which are removed from values. The remaining ones form the query.
+// It is prefixed by the host when the route is restricted to one.
+func (tmpl *urlTemplate) build(values url.Values) (string, error) {
+	var sb strings.Builder
+	if tmpl.host != nil {
+		sb.WriteString("//")
+		for i, label := range tmpl.host.labels {
+			if i != 0 {
+				sb.WriteByte('.')
+			}
+			if label.name == "" {
+				sb.WriteString(label.literal)
+				continue
+			}
+			value, err := tmpl.paramValue(values, label.name, false)
+			if err != nil {
+				return "", err
+			}
+			if !isHostLabel(value) {
+				return "", fmt.Errorf("%w: %s=%s isn't a host label", ErrInvalidParam, label.name, value)
+			}
+			sb.WriteString(value)
+		}
+	}
+
+	pathStart := sb.Len()
+	omitted := "" // First omitted optional wildcard
+	for _, segment := range tmpl.pattern.Segments {
+		var segmentSb strings.Builder
+		for _, node := range segment.Nodes {
+			switch n := node.(type) {
+			case *Literal:
+				segmentSb.WriteString(url.PathEscape(n.Value))
+			case *Param:
+				value, err := tmpl.paramValue(values, n.Name, n.Optional)
+				if err != nil {
+					return "", err
+				}
+				if value == "" {
+					omitted = n.Name
+					continue
+				}
+				segmentSb.WriteString(url.PathEscape(value))
+			case *CatchAll:
+				value, err := tmpl.paramValue(values, n.Name, n.Optional)
+				if err != nil {
+					return "", err
+				}
+				if value == "" {
+					omitted = n.Name
+					continue
+				}
+				// Keep the slashes of the remainder
+				parts := strings.Split(value, "/")
+				for i, part := range parts {
+					parts[i] = url.PathEscape(part)
+				}
+				segmentSb.WriteString(strings.Join(parts, "/"))
+			}
+		}
+
+		if segmentSb.Len() == 0 {
+			continue
+		}
+		if omitted != "" {
+			return "", fmt.Errorf("%w: %s must be provided to use the following optional parameters", ErrMissingParam, omitted)
+		}
+		sb.WriteByte('/')
+		sb.WriteString(segmentSb.String())
+	}
+
+	if sb.Len() == pathStart || tmpl.pattern.TrailingSlash {
+		sb.WriteByte('/')
+	}
+	if len(values) != 0 {
+		sb.WriteByte('?')
+		sb.WriteString(values.Encode())
+	}
+	return sb.String(), nil
+}
+
+// Pop the value of a wildcard and check its constraint, an empty value means that an optional wildcard is omitted
+func (tmpl *urlTemplate) paramValue(values url.Values, name string, optional bool) (string, error) {
+	value := values.Get(name)
+	values.Del(name)
+	if value == "" {
+		if optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("%w: %s", ErrMissingParam, name)
+	}
+
+	if _, match := tmpl.constraints[name].match(value); !match {
+		return "", fmt.Errorf("%w: %s=%s", ErrInvalidParam, name, value)
+	}
+	return value, nil
+}
+
+// Host labels are made of letters, digits and hyphens
+func isHostLabel(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '-' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return value != ""
+}
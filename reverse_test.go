@@ -0,0 +1,132 @@
+package router
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		params      []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "home",
+			expected: "/",
+		},
+		{
+			name:     "user",
+			params:   []string{"id", "42"},
+			expected: "/api/users/42",
+		},
+		{
+			name:     "user",
+			params:   []string{"id", "42", "tab", "posts", "sort", "desc", "sort", "asc"},
+			expected: "/api/users/42?sort=desc&sort=asc&tab=posts",
+		},
+		{
+			name:        "user",
+			params:      []string{"id", "abc"}, // Converter constraint violated
+			expectedErr: ErrInvalidParam,
+		},
+		{
+			name:        "user",
+			params:      []string{"tab", "posts"},
+			expectedErr: ErrMissingParam,
+		},
+		{
+			name:     "file",
+			params:   []string{"name", "my report?", "ext", "pdf"},
+			expected: "/files/my%20report%3F.pdf",
+		},
+		{
+			name:        "file",
+			params:      []string{"name", "report", "ext", "PDF"}, // Regular expression constraint violated
+			expectedErr: ErrInvalidParam,
+		},
+		{
+			name:     "static",
+			params:   []string{"path", "css/main file.css"},
+			expected: "/static/css/main%20file.css",
+		},
+		{
+			name:     "reports",
+			expected: "/reports",
+		},
+		{
+			name:     "reports",
+			params:   []string{"year", "2024", "month", "02"},
+			expected: "/reports/2024/02",
+		},
+		{
+			name:        "reports",
+			params:      []string{"month", "02"}, // Previous optional parameter omitted
+			expectedErr: ErrMissingParam,
+		},
+		{
+			name:     "docs",
+			params:   []string{"page", "intro"},
+			expected: "/docs/intro/",
+		},
+		{
+			name:     "dashboard",
+			params:   []string{"tenant", "acme", "tab", "posts"},
+			expected: "//acme.example.com/dashboard?tab=posts",
+		},
+		{
+			name:        "dashboard",
+			params:      []string{"tab", "posts"},
+			expectedErr: ErrMissingParam,
+		},
+		{
+			name:        "dashboard",
+			params:      []string{"tenant", "evil.com/x"},
+			expectedErr: ErrInvalidParam,
+		},
+		{
+			name:        "version",
+			params:      []string{"version", "v2"}, // Host converter constraint violated
+			expectedErr: ErrInvalidParam,
+		},
+		{
+			name:     "status",
+			expected: "//api.example.com/",
+		},
+		{
+			name:        "unknown",
+			expectedErr: ErrUnknownRouteName,
+		},
+	}
+
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/", okHandler, WithName("home"))
+	mux.Group("/api").HandleFunc(GET, "/users/{id:int}", okHandler, WithName("user"))
+	mux.HandleFunc(GET, "/files/{name}.{ext:[a-z]+}", okHandler, WithName("file"))
+	mux.HandleFunc(GET, "/static/{path...}", okHandler, WithName("static"))
+	mux.HandleFunc(GET, "/reports/{year?}/{month?}", okHandler, WithName("reports"))
+	mux.HandleFunc(GET, "/docs/{page}/", okHandler, WithName("docs"))
+	mux.Host("{tenant}.Example.com:8080").HandleFunc(GET, "/dashboard", okHandler, WithName("dashboard"))
+	mux.HandleFunc(GET, "/", okHandler, WithName("version"), WithHost("{version:int}.api.example.com"))
+	mux.HandleFunc(GET, "/", okHandler, WithName("status"), WithHost("api.example.com"))
+
+	for _, tc := range testCases {
+		result, err := mux.URL(tc.name, tc.params...)
+		if tc.expectedErr != nil {
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("%s %v expected error=%v, got=%v", tc.name, tc.params, tc.expectedErr, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s %v unexpected error: %v", tc.name, tc.params, err)
+		} else if result != tc.expected {
+			t.Errorf("%s %v got unexpected URL. expected=%s, got=%s", tc.name, tc.params, tc.expected, result)
+		}
+	}
+
+	// Names are unique
+	err := mux.TryHandleFunc(POST, "/users", okHandler, WithName("user"))
+	if !errors.Is(err, ErrDuplicateRouteName) {
+		t.Errorf("expected duplicate route name error, got=%v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/valsov/router/middleware"
+)
+
+// Verify interface compliance
+var _ http.Handler = &route{}
+
+// Registered route, served through its precompiled middleware chain
+type route struct {
+	method          HttpMethod
+	host            string   // Host pattern, empty for routes matching every host
+	hostParams      []string // Wildcard names of the host pattern
+	pattern         string
+	predicates      []Predicate
+	requestPattern  string // Pattern in the http.ServeMux format, for Request.Pattern
+	name            string
+	urlTemplate     *urlTemplate            // Set for named routes
+	stripPrefix     bool                    // Mounted handler receiving the path without the prefix
+	middlewareChain []middleware.Middleware // Group and route middleware
+	handler         http.Handler            // Handler wrapped by its group and route middleware
+	compiled        http.Handler            // Handler wrapped by the whole middleware chain
+}
+
+// Description of a registered route
+type RouteInfo struct {
+	Method     HttpMethod // MOUNT_METHOD for mounted handlers
+	Host       string     // Host pattern, empty for routes matching every host
+	Pattern    string
+	Name       string
+	Params     []string // Wildcard names, host ones first, in pattern order
+	Predicates []string // Predicates descriptions, in registration order
+	Middleware []string // Middleware descriptors, in execution order (see middleware.Name)
+}
+
+// Option applied to a route registration
+type RouteOption func(*routeConfig)
+
+// Route registration settings
+type routeConfig struct {
+	name            string
+	host            string
+	predicates      []Predicate
+	middlewareChain []middleware.Middleware
+	stripPrefix     bool
+}
+
+// Name the route, to build its URLs with HttpRouter.URL. Names are unique in a router.
+func WithName(name string) RouteOption {
+	return func(config *routeConfig) {
+		config.name = name
+	}
+}
+
+// Only match the requests to the host, e.g. "api.example.com" or "{tenant}.example.com".
+// Host wildcards span a whole label and are available as route parameters, the port is ignored.
+func WithHost(host string) RouteOption {
+	return func(config *routeConfig) {
+		config.host = host
+	}
+}
+
+// Only serve the requests matching all the predicates, e.g. a header value. Several routes can be registered
+// for the same method and path with different predicates: they are evaluated in registration order,
+// the route without predicates is the fallback.
+func WithPredicates(predicates ...Predicate) RouteOption {
+	return func(config *routeConfig) {
+		config.predicates = append(config.predicates, predicates...)
+	}
+}
+
+// Attach middleware to the route. Route middleware runs after the router and group middleware, in the given order.
+func WithMiddleware(middleware ...middleware.Middleware) RouteOption {
+	return func(config *routeConfig) {
+		config.middlewareChain = append(config.middlewareChain, middleware...)
+	}
+}
+
+// Remove the mount prefix from the request URL path before calling a mounted handler, like http.StripPrefix.
+// Only applies to Mount.
+func WithStripPrefix() RouteOption {
+	return func(config *routeConfig) {
+		config.stripPrefix = true
+	}
+}
+
+func newRouteConfig(opts []RouteOption) routeConfig {
+	var config routeConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// Describe the route, running after the given router middleware
+func (rt *route) info(routerMiddleware []middleware.Middleware) RouteInfo {
+	info := RouteInfo{
+		Method:  rt.method,
+		Host:    rt.host,
+		Pattern: rt.pattern,
+		Name:    rt.name,
+	}
+	info.Params = slices.Clone(rt.hostParams)
+	if pattern, err := ParsePattern(rt.pattern); err == nil {
+		info.Params = append(info.Params, pattern.ParamNames()...)
+	}
+	for _, predicate := range rt.predicates {
+		info.Predicates = append(info.Predicates, predicate.String())
+	}
+	for _, m := range routerMiddleware {
+		info.Middleware = append(info.Middleware, middleware.Name(m))
+	}
+	for _, m := range rt.middlewareChain {
+		info.Middleware = append(info.Middleware, middleware.Name(m))
+	}
+	return info
+}
+
+// Build the final handler of the route with the router middleware
+func (rt *route) compile(routerMiddleware []middleware.Middleware) {
+	rt.compiled = middleware.GetHandlerChain(rt.handler, routerMiddleware)
+}
+
+func (rt *route) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rt.compiled.ServeHTTP(w, req)
+}
+
+// Handler mounted under a prefix
+type mountHandler struct {
+	handler     http.Handler
+	stripPrefix bool
+}
+
+func (m *mountHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !m.stripPrefix {
+		m.handler.ServeHTTP(w, req)
+		return
+	}
+
+	ctx, _ := getRequestContext(req)
+	strippedReq := new(http.Request)
+	*strippedReq = *req
+	strippedReq.URL = new(url.URL)
+	*strippedReq.URL = *req.URL
+	if ctx.escapedPath {
+		strippedReq.URL.Path, _ = url.PathUnescape(ctx.MountPath)
+		strippedReq.URL.RawPath = escapeRoutingPath(ctx.MountPath)
+	} else {
+		strippedReq.URL.Path = ctx.MountPath
+		strippedReq.URL.RawPath = ""
+	}
+	m.handler.ServeHTTP(w, strippedReq)
+}
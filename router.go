@@ -4,6 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/valsov/router/middleware"
 )
@@ -12,39 +17,248 @@ import (
 var _ http.Handler = &HttpRouter{}
 
 type HttpRouter struct {
-	tree            *tree
+	// Handler called when no route matches the request, defaults to http.NotFound
+	NotFound http.Handler
+	// Handler called when the route is only registered for other methods, defaults to a plain 405 response.
+	// The Allow header is already set when it is called.
+	MethodNotAllowed http.Handler
+
+	// Serve HEAD requests with the GET handler when no HEAD handler is registered, the response body is discarded
+	HandleHead bool
+	// Automatically answer OPTIONS requests when no OPTIONS handler is registered
+	HandleOptions bool
+	// Handler called for automatic OPTIONS responses, defaults to a 204 response.
+	// The Allow header is already set when it is called, and it runs through the middleware chain (e.g. for CORS).
+	Options http.Handler
+
+	// Handling of the paths only matching a route with a trailing slash added or removed, defaults to PATH_LENIENT
+	TrailingSlash PathPolicy
+	// Handling of the paths containing "." or ".." segments, or duplicate slashes, defaults to PATH_REDIRECT.
+	// With PATH_LENIENT, the handler and the middleware get the cleaned path.
+	CleanPath PathPolicy
+	// Handling of the paths only matching a route with another casing of its static parts, defaults to PATH_STRICT.
+	// Route parameter values keep their original case.
+	Casing PathPolicy
+	// Route on the escaped path (URL.EscapedPath) rather than the decoded one: escaped slashes don't separate
+	// segments and each captured parameter is unescaped individually, "/files/a%2Fb" matches "/files/{name}"
+	// with name "a/b". Other escaped characters are decoded before matching the static parts of the routes.
+	UseRawPath bool
+
+	tree            *tree            // Routes matching every host
+	hosts           map[string]*tree // Routes of exact host names
+	wildcardHosts   []*hostPattern   // Host patterns with wildcards, the ones with the most static labels first
 	middlewareChain []middleware.Middleware
+	routes          []*route
+	namedRoutes     map[string]*route
+	buildMutex      sync.Mutex
+	stale           atomic.Bool // Routes must be compiled again, set when the middleware chain changes
 }
 
 func NewHttpRouter() *HttpRouter {
 	return &HttpRouter{
 		tree:            NewTree(),
+		hosts:           make(map[string]*tree),
 		middlewareChain: []middleware.Middleware{},
+		namedRoutes:     make(map[string]*route),
+		TrailingSlash:   PATH_LENIENT,
+		CleanPath:       PATH_REDIRECT,
 	}
 }
 
 // Configuration functions
 
-func (r *HttpRouter) Handle(method HttpMethod, route string, handler http.Handler) *HttpRouter {
-	r.tree.Register(method, route, handler)
+// Can panic, see TryHandle
+func (r *HttpRouter) Handle(method HttpMethod, route string, handler http.Handler, opts ...RouteOption) *HttpRouter {
+	if err := r.TryHandle(method, route, handler, opts...); err != nil {
+		panic(err)
+	}
 	return r
 }
 
-func (r *HttpRouter) HandleFunc(method HttpMethod, route string, handler http.HandlerFunc) *HttpRouter {
-	r.tree.Register(method, route, handler)
+// Can panic, see TryHandleFunc
+func (r *HttpRouter) HandleFunc(method HttpMethod, route string, handler http.HandlerFunc, opts ...RouteOption) *HttpRouter {
+	return r.Handle(method, route, handler, opts...)
+}
+
+// Register the handler for the route, errors are of type *RegistrationError
+func (r *HttpRouter) TryHandle(method HttpMethod, pattern string, handler http.Handler, opts ...RouteOption) error {
+	config := newRouteConfig(opts)
+	return r.addRoute(method, pattern, handler, config, func(t *tree, registered *route) error {
+		return t.Register(method, pattern, registered, config.predicates...)
+	})
+}
+
+// Register the handler function for the route, errors are of type *RegistrationError
+func (r *HttpRouter) TryHandleFunc(method HttpMethod, route string, handler http.HandlerFunc, opts ...RouteOption) error {
+	return r.TryHandle(method, route, handler, opts...)
+}
+
+// Can panic, see TryMount
+func (r *HttpRouter) Mount(prefix string, handler http.Handler, opts ...RouteOption) *HttpRouter {
+	if err := r.TryMount(prefix, handler, opts...); err != nil {
+		panic(err)
+	}
 	return r
 }
 
-func (r *HttpRouter) UseMiddleware(middleware middleware.Middleware) *HttpRouter {
-	r.middlewareChain = append(r.middlewareChain, middleware)
+// Forward the requests of every method, for the prefix and every path below it, to the handler.
+// Routes registered on the router are favored. Mounted HttpRouter instances can access the route parameters of the prefix.
+// Errors are of type *RegistrationError.
+func (r *HttpRouter) TryMount(prefix string, handler http.Handler, opts ...RouteOption) error {
+	config := newRouteConfig(opts)
+	handler = &mountHandler{handler, config.stripPrefix}
+	return r.addRoute(MOUNT_METHOD, prefix, handler, config, func(t *tree, registered *route) error {
+		return t.RegisterMount(prefix, registered, config.predicates...)
+	})
+}
+
+// Build the route and register it in the tree of its host with the given function, then reference it in the router
+func (r *HttpRouter) addRoute(method HttpMethod, pattern string, handler http.Handler, config routeConfig, register func(*tree, *route) error) error {
+	if config.name != "" {
+		if _, found := r.namedRoutes[config.name]; found {
+			return &RegistrationError{method, pattern, fmt.Errorf("%w: %s", ErrDuplicateRouteName, config.name)}
+		}
+	}
+
+	t, hostParams, err := r.hostTree(config.host)
+	if err != nil {
+		return &RegistrationError{method, pattern, err}
+	}
+	if parsed, err := ParsePattern(pattern); err == nil {
+		// Invalid patterns are reported by the registration
+		for _, name := range parsed.ParamNames() {
+			if slices.Contains(hostParams, name) {
+				return &RegistrationError{method, pattern, fmt.Errorf("%w: duplicated wildcard parameter name in host: %s", ErrInvalidPattern, name)}
+			}
+		}
+	}
+
+	registered := &route{
+		method:          method,
+		host:            config.host,
+		hostParams:      hostParams,
+		pattern:         pattern,
+		predicates:      config.predicates,
+		requestPattern:  standardPattern(method, config.host, pattern),
+		name:            config.name,
+		stripPrefix:     config.stripPrefix,
+		middlewareChain: config.middlewareChain,
+		handler:         middleware.GetHandlerChain(handler, config.middlewareChain),
+	}
+	registered.compile(r.middlewareChain)
+	if err := register(t, registered); err != nil {
+		return err
+	}
+
+	r.routes = append(r.routes, registered)
+	if config.name != "" {
+		template, err := r.tree.newUrlTemplate(pattern)
+		if err != nil {
+			// Unreachable: the pattern was validated by the registration
+			return &RegistrationError{method, pattern, err}
+		}
+		if config.host != "" {
+			host, err := r.tree.parseHostPattern(config.host)
+			if err != nil {
+				// Unreachable: the host was validated by the registration
+				return &RegistrationError{method, pattern, err}
+			}
+			template.setHost(host)
+		}
+		registered.urlTemplate = template
+		r.namedRoutes[config.name] = registered
+	}
+	return nil
+}
+
+// Tree of the routes of the host pattern, created on first use, along with the host wildcard names.
+// Routes without host pattern are stored in the default tree.
+func (r *HttpRouter) hostTree(pattern string) (*tree, []string, error) {
+	if pattern == "" {
+		return r.tree, nil, nil
+	}
+
+	host, err := r.tree.parseHostPattern(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	if host.isExact() {
+		t, found := r.hosts[host.pattern]
+		if !found {
+			t = r.tree.newHostTree()
+			r.hosts[host.pattern] = t
+		}
+		return t, nil, nil
+	}
+
+	for _, existing := range r.wildcardHosts {
+		if existing.pattern == host.pattern {
+			return existing.tree, existing.paramNames(), nil
+		}
+	}
+	host.tree = r.tree.newHostTree()
+	index := slices.IndexFunc(r.wildcardHosts, func(existing *hostPattern) bool {
+		return existing.statics < host.statics
+	})
+	if index == -1 {
+		index = len(r.wildcardHosts)
+	}
+	r.wildcardHosts = slices.Insert(r.wildcardHosts, index, host)
+	return host.tree, host.paramNames(), nil
+}
+
+// Describe the registered routes, sorted by pattern, method then host
+func (r *HttpRouter) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(r.routes))
+	for i, registered := range r.routes {
+		routes[i] = registered.info(r.middlewareChain)
+	}
+	slices.SortStableFunc(routes, func(a, b RouteInfo) int {
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.Method), string(b.Method)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Host, b.Host)
+	})
+	return routes
+}
+
+// Register a named converter, usable as wildcard constraint in routes registered afterwards: "{name:converter}".
+// Default converters can be overridden.
+func (r *HttpRouter) RegisterConverter(name string, converter ParamConverter) *HttpRouter {
+	r.tree.RegisterConverter(name, converter)
 	return r
 }
 
+// Add middleware running for every route. Routes handlers are compiled again on Build or on the next request.
+func (r *HttpRouter) UseMiddleware(middleware middleware.Middleware) *HttpRouter {
+	return r.UseMiddlewares(middleware)
+}
+
+// Add middleware running for every route. Routes handlers are compiled again on Build or on the next request.
 func (r *HttpRouter) UseMiddlewares(middleware ...middleware.Middleware) *HttpRouter {
 	r.middlewareChain = append(r.middlewareChain, middleware...)
+	r.stale.Store(true)
 	return r
 }
 
+// Compile the middleware chain of every route, if it changed since the last build.
+// Calling it before serving requests is optional: it avoids compiling the routes on the first request.
+func (r *HttpRouter) Build() {
+	r.buildMutex.Lock()
+	defer r.buildMutex.Unlock()
+	if !r.stale.Load() {
+		return
+	}
+
+	for _, registered := range r.routes {
+		registered.compile(r.middlewareChain)
+	}
+	r.stale.Store(false)
+}
+
 func (r *HttpRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var method HttpMethod
 	if req.Method == "" {
@@ -53,24 +267,242 @@ func (r *HttpRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		method = HttpMethod(req.Method)
 	}
 
-	routeData, err := r.tree.Find(method, req.URL)
+	if r.stale.Load() {
+		r.Build()
+	}
+
+	rCtx := acquireRequestContext(req)
+	defer releaseRequestContext(rCtx)
+
+	requestPath := req.URL.Path
+	if r.UseRawPath {
+		requestPath = routingPath(req.URL.EscapedPath())
+		rCtx.escapedPath = true
+	}
+
+	routeData, path, redirect, err := r.findHost(method, req.Host, requestPath, rCtx)
+	if errors.Is(err, ErrMethodNotAllowed) {
+		if method == HEAD && r.HandleHead && slices.Contains(routeData.AllowedMethods, GET) {
+			// Serve with the GET handler, without body
+			routeData, path, redirect, err = r.findHost(GET, req.Host, requestPath, rCtx)
+			w = &headResponseWriter{w}
+		} else if method == OPTIONS && r.HandleOptions {
+			r.options(w, req, r.allowedMethods(routeData.AllowedMethods))
+			return
+		}
+	}
+
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
-			http.NotFound(w, req)
+			r.notFound(w, req)
+		} else if errors.Is(err, ErrMethodNotAllowed) {
+			r.methodNotAllowed(w, req, r.allowedMethods(routeData.AllowedMethods))
+		} else if errors.Is(err, ErrUnhandledMethod) {
+			http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
 		} else {
 			panic(fmt.Sprintf("unhandled error finding request handler: %v", err))
 		}
 
 		return
 	}
+	if redirect {
+		r.redirect(w, req, method, path)
+		return
+	}
+
+	// Store route data in a context owned by the request, keeping the parameters of an outer router mounting this one
+	matched := routeData.Handler.(*route)
+	matchedCtx := rCtx.detach()
+	matchedCtx.Pattern = matched.pattern
+	matchedCtx.RouteName = matched.name
+	matchedCtx.Method = matched.method
+	if outerCtx, found := getRequestContext(req); found && outerCtx.Method == MOUNT_METHOD {
+		matchedCtx.inherit(outerCtx)
+	}
+	if matched.stripPrefix {
+		matchedCtx.mountPrefix = matchedCtx.Pattern
+	}
+
+	*req = *req.WithContext(matchedCtx)
+	if r.CleanPath == PATH_LENIENT {
+		r.setCleanedPath(req, requestPath)
+	}
+	setStandardRequestFields(req, matchedCtx, matched)
+
+	// Request execution, through the precompiled middleware chain
+	routeData.Handler.ServeHTTP(w, req)
+}
+
+// Find the route in the trees of the hosts matching the request host, exact host names first, then host patterns.
+// The routes matching every host are searched last. Methods allowed by any of these trees are reported together.
+func (r *HttpRouter) findHost(method HttpMethod, host, path string, rCtx *requestContext) (routeData, string, bool, error) {
+	var allowed []HttpMethod
+	if len(r.hosts) != 0 || len(r.wildcardHosts) != 0 {
+		host = normalizeHost(host)
+		if t, found := r.hosts[host]; found {
+			data, foundPath, redirect, err := r.find(t, method, path, rCtx)
+			if err == nil || errors.Is(err, ErrUnhandledMethod) {
+				return data, foundPath, redirect, err
+			}
+			allowed = appendMissing(allowed, data.AllowedMethods)
+		}
+
+		for _, hostPattern := range r.wildcardHosts {
+			length := len(rCtx.params)
+			if !hostPattern.match(host, &rCtx.params) {
+				continue
+			}
+			data, foundPath, redirect, err := r.find(hostPattern.tree, method, path, rCtx)
+			if err == nil || errors.Is(err, ErrUnhandledMethod) {
+				return data, foundPath, redirect, err
+			}
+			allowed = appendMissing(allowed, data.AllowedMethods)
+			rCtx.params = rCtx.params[:length]
+		}
+	}
+
+	data, foundPath, redirect, err := r.find(r.tree, method, path, rCtx)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrMethodNotAllowed) {
+		if allowed = appendMissing(allowed, data.AllowedMethods); len(allowed) != 0 {
+			return routeData{AllowedMethods: allowed}, path, false, ErrMethodNotAllowed
+		}
+	}
+	return data, foundPath, redirect, err
+}
+
+// Append the methods which are not already in the list
+func appendMissing(methods, others []HttpMethod) []HttpMethod {
+	for _, method := range others {
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// Find the route of the path in the tree according to the path policies. Return the path used to find it,
+// and whether the client must be redirected to this path.
+func (r *HttpRouter) find(t *tree, method HttpMethod, path string, rCtx *requestContext) (routeData, string, bool, error) {
+	lookupPath := path
+	if r.CleanPath != PATH_STRICT {
+		lookupPath = cleanPath(path)
+	}
+	redirect := r.CleanPath == PATH_REDIRECT && lookupPath != path
+
+	routeData, err := t.Find(method, lookupPath, rCtx)
+	if !errors.Is(err, ErrNotFound) {
+		return routeData, lookupPath, redirect, err
+	}
+
+	// Path variants, tried in order
+	alternatePath, hasAlternate := "", false
+	if r.TrailingSlash != PATH_STRICT {
+		alternatePath, hasAlternate = toggleTrailingSlash(lookupPath)
+	}
+	if hasAlternate {
+		if alternateData, alternateErr := t.Find(method, alternatePath, rCtx); !errors.Is(alternateErr, ErrNotFound) {
+			return alternateData, alternatePath, redirect || r.TrailingSlash == PATH_REDIRECT, alternateErr
+		}
+	}
+	if r.Casing != PATH_STRICT {
+		// Fall back to a case-insensitive search
+		if foldData, canonicalPath, foldErr := t.FindCaseInsensitive(method, lookupPath, rCtx); !errors.Is(foldErr, ErrNotFound) {
+			return foldData, canonicalPath, redirect || r.Casing == PATH_REDIRECT, foldErr
+		}
+		if hasAlternate {
+			if foldData, canonicalPath, foldErr := t.FindCaseInsensitive(method, alternatePath, rCtx); !errors.Is(foldErr, ErrNotFound) {
+				return foldData, canonicalPath, redirect || r.Casing == PATH_REDIRECT || r.TrailingSlash == PATH_REDIRECT, foldErr
+			}
+		}
+	}
+	return routeData, lookupPath, redirect, err
+}
+
+// Redirect the client to the path, keeping the query
+func (r *HttpRouter) redirect(w http.ResponseWriter, req *http.Request, method HttpMethod, path string) {
+	code := http.StatusPermanentRedirect
+	if method == GET {
+		code = http.StatusMovedPermanently
+	}
+	target := url.URL{Path: path, RawQuery: req.URL.RawQuery}
+	if r.UseRawPath {
+		// Slashes and percent signs are still escaped
+		target.Path, _ = url.PathUnescape(path)
+		target.RawPath = escapeRoutingPath(path)
+	}
+	http.Redirect(w, req, target.RequestURI(), code)
+}
+
+// Replace the request path by its cleaned version, so that the middleware check the path of the served route
+func (r *HttpRouter) setCleanedPath(req *http.Request, requestPath string) {
+	cleaned := cleanPath(requestPath)
+	if cleaned == requestPath {
+		return
+	}
 
-	// Store route data in context
-	reqWithContext := newRequestWithContext(req, routeData.Context)
-	*req = *reqWithContext
+	cleanedURL := new(url.URL)
+	*cleanedURL = *req.URL
+	if r.UseRawPath {
+		// Slashes and percent signs are still escaped
+		cleanedURL.Path, _ = url.PathUnescape(cleaned)
+		cleanedURL.RawPath = escapeRoutingPath(cleaned)
+	} else {
+		cleanedURL.Path = cleaned
+		cleanedURL.RawPath = ""
+	}
+	req.URL = cleanedURL
+}
 
-	// Middleware chain
-	handler := middleware.GetHandlerChain(routeData.Handler, r.middlewareChain)
+func (r *HttpRouter) notFound(w http.ResponseWriter, req *http.Request) {
+	if r.NotFound != nil {
+		r.NotFound.ServeHTTP(w, req)
+	} else {
+		http.NotFound(w, req)
+	}
+}
+
+func (r *HttpRouter) methodNotAllowed(w http.ResponseWriter, req *http.Request, allowed []HttpMethod) {
+	w.Header().Set("Allow", joinMethods(allowed))
+	if r.MethodNotAllowed != nil {
+		r.MethodNotAllowed.ServeHTTP(w, req)
+	} else {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
 
-	// Request execution
-	handler.ServeHTTP(w, reqWithContext)
+func (r *HttpRouter) options(w http.ResponseWriter, req *http.Request, allowed []HttpMethod) {
+	w.Header().Set("Allow", joinMethods(allowed))
+	var handler http.Handler
+	if r.Options != nil {
+		handler = r.Options
+	} else {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	middleware.GetHandlerChain(handler, r.middlewareChain).ServeHTTP(w, req)
+}
+
+// Add the automatically handled methods to the registered ones
+func (r *HttpRouter) allowedMethods(registered []HttpMethod) []HttpMethod {
+	allowed := slices.Clone(registered)
+	if r.HandleHead && slices.Contains(allowed, GET) && !slices.Contains(allowed, HEAD) {
+		allowed = append(allowed, HEAD)
+	}
+	if r.HandleOptions && !slices.Contains(allowed, OPTIONS) {
+		allowed = append(allowed, OPTIONS)
+	}
+	return allowed
+}
+
+// Format methods as an Allow header value
+func joinMethods(methods []HttpMethod) string {
+	var sb strings.Builder
+	for i, method := range methods {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(string(method))
+	}
+	return sb.String()
 }
@@ -0,0 +1,1284 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/valsov/router/middleware"
+)
+
+func TestServeHTTP(t *testing.T) {
+	testCases := []struct {
+		method         HttpMethod
+		route          string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{
+			method:         GET,
+			route:          "/users",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			method:         GET,
+			route:          "/notfound",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			method:         DELETE,
+			route:          "/users",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET, POST",
+		},
+	}
+
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users", okHandler)
+	mux.HandleFunc(POST, "/users", okHandler)
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(string(tc.method), tc.route, nil))
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%s] %s got unexpected status. expected=%d, got=%d", tc.method, tc.route, tc.expectedStatus, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.expectedAllow {
+			t.Errorf("[%s] %s got unexpected Allow header. expected=%q, got=%q", tc.method, tc.route, tc.expectedAllow, allow)
+		}
+	}
+}
+
+func TestCustomErrorHandlers(t *testing.T) {
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users", okHandler)
+	mux.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	mux.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/notfound", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("custom not found handler wasn't called. expected=%d, got=%d", http.StatusTeapot, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/users", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("custom method not allowed handler wasn't called. expected=%d, got=%d", http.StatusConflict, w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET" {
+		t.Errorf("got unexpected Allow header. expected=%q, got=%q", "GET", allow)
+	}
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestAutomaticHeadAndOptions(t *testing.T) {
+	testCases := []struct {
+		method         HttpMethod
+		route          string
+		handleHead     bool
+		handleOptions  bool
+		expectedStatus int
+		expectedAllow  string
+		expectedBody   string
+	}{
+		{
+			// GET handler used, body discarded
+			method:         HEAD,
+			route:          "/users",
+			handleHead:     true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			method:         HEAD,
+			route:          "/users",
+			handleHead:     false,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET, POST",
+			expectedBody:   "Method Not Allowed\n",
+		},
+		{
+			// Explicitly registered HEAD handler
+			method:         HEAD,
+			route:          "/explicit",
+			handleHead:     true,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "explicit",
+		},
+		{
+			method:         OPTIONS,
+			route:          "/users",
+			handleHead:     true,
+			handleOptions:  true,
+			expectedStatus: http.StatusNoContent,
+			expectedAllow:  "GET, POST, HEAD, OPTIONS",
+		},
+		{
+			method:         OPTIONS,
+			route:          "/users",
+			handleOptions:  false,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET, POST",
+			expectedBody:   "Method Not Allowed\n",
+		},
+		{
+			// Explicitly registered OPTIONS handler
+			method:         OPTIONS,
+			route:          "/explicit",
+			handleOptions:  true,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "explicit",
+		},
+		{
+			method:         OPTIONS,
+			route:          "/notfound",
+			handleOptions:  true,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+	}
+
+	explicitHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("explicit"))
+	}
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	mux.HandleFunc(POST, "/users", okHandler)
+	mux.HandleFunc(HEAD, "/explicit", explicitHandler)
+	mux.HandleFunc(OPTIONS, "/explicit", explicitHandler)
+
+	for _, tc := range testCases {
+		mux.HandleHead = tc.handleHead
+		mux.HandleOptions = tc.handleOptions
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(string(tc.method), tc.route, nil))
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%s] %s got unexpected status. expected=%d, got=%d", tc.method, tc.route, tc.expectedStatus, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.expectedAllow {
+			t.Errorf("[%s] %s got unexpected Allow header. expected=%q, got=%q", tc.method, tc.route, tc.expectedAllow, allow)
+		}
+		if body := w.Body.String(); body != tc.expectedBody {
+			t.Errorf("[%s] %s got unexpected body. expected=%q, got=%q", tc.method, tc.route, tc.expectedBody, body)
+		}
+	}
+}
+
+func TestTryHandle(t *testing.T) {
+	mux := NewHttpRouter()
+	if err := mux.TryHandleFunc(GET, "/users/{id}", okHandler); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+
+	err := mux.TryHandleFunc(GET, "/users/{id}", okHandler)
+	var registrationErr *RegistrationError
+	if !errors.As(err, &registrationErr) || !errors.Is(err, ErrDuplicateRoute) {
+		t.Errorf("expected duplicate route registration error, got=%v", err)
+	}
+
+	err = mux.TryHandleFunc(GET, "/users/{id", okHandler)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected invalid pattern error, got=%v", err)
+	} else if syntaxErr.Offset != 7 {
+		t.Errorf("got unexpected syntax error offset. expected=%d, got=%d", 7, syntaxErr.Offset)
+	}
+
+	// Chaining API panics
+	err = checkPanic(func() {
+		mux.HandleFunc(GET, "/users/{id}", okHandler)
+	}, true)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	testCases := []struct {
+		route         string
+		expectedTrace string
+	}{
+		{
+			route:         "/health",
+			expectedTrace: "global,handler",
+		},
+		{
+			route:         "/api/v1/users",
+			expectedTrace: "global,api,handler",
+		},
+		{
+			route:         "/api/v1/admin/users/42",
+			expectedTrace: "global,api,admin1,admin2,handler",
+		},
+		{
+			route:         "/upload",
+			expectedTrace: "global,route1,route2,handler",
+		},
+		{
+			route:         "/api/v1/admin/upload",
+			expectedTrace: "global,api,admin1,admin2,route1,handler",
+		},
+	}
+
+	traceHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Trace", "handler")
+	}
+	mux := NewHttpRouter()
+	mux.UseMiddleware(traceMiddleware("global"))
+	mux.HandleFunc(GET, "/health", traceHandler)
+
+	api := mux.Group("/api/v1/", traceMiddleware("api"))
+	api.HandleFunc(GET, "users", traceHandler)
+	admin := api.Group("/admin", traceMiddleware("admin1"), traceMiddleware("admin2"))
+	admin.HandleFunc(GET, "/users/{id}", traceHandler)
+
+	// Per-route middleware
+	mux.HandleFunc(GET, "/upload", traceHandler, WithMiddleware(traceMiddleware("route1")), WithMiddleware(traceMiddleware("route2")))
+	admin.HandleFunc(GET, "/upload", traceHandler, WithMiddleware(traceMiddleware("route1")))
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tc.route, nil))
+
+		if trace := strings.Join(w.Header().Values("Trace"), ","); trace != tc.expectedTrace {
+			t.Errorf("%s got unexpected middleware trace. expected=%s, got=%s", tc.route, tc.expectedTrace, trace)
+		}
+	}
+}
+
+func TestMiddlewareAddedAfterRegistration(t *testing.T) {
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Trace", "handler")
+	})
+
+	expectedTraces := []string{"handler", "m1,handler", "m1,m2,handler"}
+	for i, expected := range expectedTraces {
+		switch i {
+		case 1:
+			mux.UseMiddleware(traceMiddleware("m1")) // Compiled lazily on the next request
+		case 2:
+			mux.UseMiddleware(traceMiddleware("m2"))
+			mux.Build()
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+		if trace := strings.Join(w.Header().Values("Trace"), ","); trace != expected {
+			t.Errorf("got unexpected middleware trace. expected=%s, got=%s", expected, trace)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	testCases := []struct {
+		method       HttpMethod
+		route        string
+		expectedBody string
+	}{
+		{
+			method:       GET,
+			route:        "/legacy",
+			expectedBody: "legacy /legacy",
+		},
+		{
+			method:       DELETE,
+			route:        "/legacy/a/b",
+			expectedBody: "legacy /legacy/a/b",
+		},
+		{
+			method:       GET,
+			route:        "/legacy/explicit", // Favor registered routes
+			expectedBody: "explicit",
+		},
+		{
+			method:       POST,
+			route:        "/legacy/explicit", // Not registered for this method
+			expectedBody: "legacy /legacy/explicit",
+		},
+		{
+			method:       GET,
+			route:        "/files",
+			expectedBody: "files /",
+		},
+		{
+			method:       GET,
+			route:        "/files/dir/",
+			expectedBody: "files /dir/",
+		},
+		{
+			method:       "PROPFIND",
+			route:        "/files/dir/file.txt",
+			expectedBody: "files /dir/file.txt",
+		},
+		{
+			method:       GET,
+			route:        "/tenants/acme/users/42", // Share route parameters with the mounted router
+			expectedBody: "tenant=acme id=42",
+		},
+		{
+			method:       GET,
+			route:        "/api/v1/docs/index.html",
+			expectedBody: "global,api,docs /index.html",
+		},
+	}
+
+	pathHandler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + r.URL.Path))
+		}
+	}
+	subRouter := NewHttpRouter()
+	subRouter.HandleFunc(GET, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ := GetRouteParam(r, "tenant")
+		id, _ := GetRouteParam(r, "id")
+		w.Write([]byte("tenant=" + tenant + " id=" + id))
+	})
+
+	mux := NewHttpRouter()
+	mux.Mount("/legacy", pathHandler("legacy"))
+	mux.HandleFunc(GET, "/legacy/explicit", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("explicit"))
+	})
+	mux.Mount("/files", pathHandler("files"), WithStripPrefix())
+	mux.Mount("/tenants/{tenant}", subRouter, WithStripPrefix())
+	mux.UseMiddleware(traceMiddleware("global"))
+	mux.Group("/api/v1", traceMiddleware("api")).Mount("/docs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(w.Header().Values("Trace"), ",") + " " + r.URL.Path))
+	}), WithStripPrefix(), WithMiddleware(traceMiddleware("docs")))
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(string(tc.method), tc.route, nil))
+
+		if body := w.Body.String(); body != tc.expectedBody {
+			t.Errorf("[%s] %s got unexpected body. expected=%q, got=%q", tc.method, tc.route, tc.expectedBody, body)
+		}
+	}
+
+	// Catch-all can't be used in a prefix
+	err := mux.TryMount("/static/{path...}", pathHandler("static"))
+	if !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected invalid pattern error, got=%v", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	mux := NewHttpRouter()
+	mux.UseMiddleware(middleware.LoggerMiddleware(nil))
+	mux.HandleFunc(POST, "/users", okHandler)
+	mux.HandleFunc(GET, "/users", okHandler, WithName("users"))
+	mux.Group("/users", traceMiddleware("group")).HandleFunc(GET, "/{id:int}/files/{path...}", okHandler, WithMiddleware(traceMiddleware("route")))
+	mux.Mount("/debug", http.NotFoundHandler())
+
+	expected := []RouteInfo{
+		{
+			Method:     MOUNT_METHOD,
+			Pattern:    "/debug",
+			Middleware: []string{"middleware.LoggerMiddleware"},
+		},
+		{
+			Method:     GET,
+			Pattern:    "/users",
+			Name:       "users",
+			Middleware: []string{"middleware.LoggerMiddleware"},
+		},
+		{
+			Method:     POST,
+			Pattern:    "/users",
+			Middleware: []string{"middleware.LoggerMiddleware"},
+		},
+		{
+			Method:     GET,
+			Pattern:    "/users/{id:int}/files/{path...}",
+			Params:     []string{"id", "path"},
+			Middleware: []string{"middleware.LoggerMiddleware", "router.traceMiddleware", "router.traceMiddleware"},
+		},
+	}
+
+	routes := mux.Routes()
+	if !reflect.DeepEqual(expected, routes) {
+		t.Errorf("got unexpected routes.\nexpected=%+v\ngot=%+v", expected, routes)
+	}
+}
+
+func TestRouteContext(t *testing.T) {
+	testCases := []struct {
+		method       HttpMethod
+		route        string
+		expectedBody string
+	}{
+		{
+			method:       GET,
+			route:        "/users/42",
+			expectedBody: "GET /users/{id} user",
+		},
+		{
+			method:       HEAD, // Served by the GET route
+			route:        "/users/42",
+			expectedBody: "GET /users/{id} user",
+		},
+		{
+			method:       GET,
+			route:        "/tenants/acme/users/42", // Mounted router with stripped prefix
+			expectedBody: "GET /tenants/{tenant}/users/{id} ",
+		},
+		{
+			method:       GET,
+			route:        "/tenants/acme/v1/orders/42", // Nested mounted routers
+			expectedBody: "GET /tenants/{tenant}/v1/orders/{id} order",
+		},
+		{
+			method:       GET,
+			route:        "/legacy/a/b",
+			expectedBody: "* /legacy ",
+		},
+	}
+
+	var body bytes.Buffer
+	recordingMiddleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			method, _ := GetRouteMethod(r)
+			pattern, _ := GetRoutePattern(r)
+			name, _ := GetRouteName(r)
+			body.WriteString(fmt.Sprintf("%s %s %s", method, pattern, name))
+		})
+	}
+	innerRouter := NewHttpRouter()
+	innerRouter.HandleFunc(GET, "/v1/orders/{id}", okHandler, WithName("order"), WithMiddleware(recordingMiddleware))
+	tenantRouter := NewHttpRouter()
+	tenantRouter.HandleFunc(GET, "/users/{id}", okHandler, WithMiddleware(recordingMiddleware))
+	tenantRouter.Mount("/v1", innerRouter)
+
+	mux := NewHttpRouter()
+	mux.HandleHead = true
+	mux.HandleFunc(GET, "/users/{id}", okHandler, WithName("user"), WithMiddleware(recordingMiddleware))
+	mux.Mount("/tenants/{tenant}", tenantRouter, WithStripPrefix())
+	mux.Mount("/legacy", http.HandlerFunc(okHandler), WithMiddleware(recordingMiddleware))
+
+	for _, tc := range testCases {
+		body.Reset()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(string(tc.method), tc.route, nil))
+
+		if body.String() != tc.expectedBody {
+			t.Errorf("[%s] %s got unexpected route data. expected=%q, got=%q", tc.method, tc.route, tc.expectedBody, body.String())
+		}
+	}
+}
+
+func TestPathValue(t *testing.T) {
+	testCases := []struct {
+		route        string
+		expectedBody string
+	}{
+		{
+			route:        "/users/42",
+			expectedBody: "42 ",
+		},
+		{
+			route:        "/files/a/b.txt",
+			expectedBody: " a/b.txt",
+		},
+		{
+			route:        "/tenants/acme/users/42", // Outer parameters are kept in mounted routers
+			expectedBody: "42 acme",
+		},
+	}
+
+	tenantRouter := NewHttpRouter()
+	tenantRouter.HandleFunc(GET, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.PathValue("id"), r.PathValue("tenant"))
+	})
+
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.PathValue("id"), r.PathValue("path"))
+	})
+	mux.HandleFunc(GET, "/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.PathValue("id"), r.PathValue("path"))
+	})
+	mux.Mount("/tenants/{tenant}", tenantRouter, WithStripPrefix())
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.route, nil))
+
+		if recorder.Body.String() != tc.expectedBody {
+			t.Errorf("%s got unexpected path values. expected=%q, got=%q", tc.route, tc.expectedBody, recorder.Body.String())
+		}
+	}
+}
+
+func TestRequestContextLifetime(t *testing.T) {
+	var keptReq *http.Request
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if keptReq == nil {
+			keptReq = r
+		}
+		id, _ := GetRouteParam(r, "id")
+		page, _ := GetQueryParam(r, "page")
+		fmt.Fprintf(w, "%s %s", id, page)
+	})
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/42?page=2", nil))
+	if body := recorder.Body.String(); body != "42 2" {
+		t.Errorf("got unexpected body. expected=%q, got=%q", "42 2", body)
+	}
+
+	// The context kept by the handler isn't recycled by the next requests
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil))
+	if id, _ := GetRouteParam(keptReq, "id"); id != "42" {
+		t.Errorf("got unexpected route parameter after the request was served. expected=%q, got=%q", "42", id)
+	}
+	if err := keptReq.Context().Err(); err != nil {
+		t.Errorf("got unexpected context error: %v", err)
+	}
+
+	// Serving the request again doesn't inherit the parameters of the previous match, only mounts do
+	other := NewHttpRouter()
+	other.HandleFunc(GET, "/users/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if _, found := GetRouteParam(r, "id"); found {
+			t.Errorf("got unexpected route parameter of the previous match")
+		}
+		if pattern, _ := GetRoutePattern(r); pattern != "/users/{name}" {
+			t.Errorf("got unexpected route pattern. expected=%q, got=%q", "/users/{name}", pattern)
+		}
+	})
+	other.ServeHTTP(httptest.NewRecorder(), keptReq)
+}
+
+func TestPathPolicies(t *testing.T) {
+	testCases := []struct {
+		trailingSlash    PathPolicy
+		cleanPath        PathPolicy
+		method           HttpMethod
+		route            string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			method:         GET,
+			route:          "/users/",
+			trailingSlash:  PATH_LENIENT,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/users /users/",
+		},
+		{
+			method:         GET,
+			route:          "/docs", // Registered with a trailing slash
+			trailingSlash:  PATH_LENIENT,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/docs/ /docs",
+		},
+		{
+			method:         GET,
+			route:          "/docs/",
+			trailingSlash:  PATH_STRICT,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/docs/ /docs/",
+		},
+		{
+			method:         GET,
+			route:          "//files/../users",
+			cleanPath:      PATH_LENIENT,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/users /users", // The handler gets the cleaned path
+		},
+		{
+			method:         GET,
+			route:          "/users/",
+			trailingSlash:  PATH_STRICT,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+		{
+			method:         GET,
+			route:          "//users",
+			cleanPath:      PATH_STRICT,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+		{
+			method:         GET,
+			route:          "/files/../users", // Unset policies are strict
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+		{
+			method:           GET,
+			route:            "/users/?page=2",
+			trailingSlash:    PATH_REDIRECT,
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/users?page=2",
+		},
+		{
+			method:           POST,
+			route:            "/users/",
+			trailingSlash:    PATH_REDIRECT,
+			expectedStatus:   http.StatusPermanentRedirect,
+			expectedLocation: "/users",
+		},
+		{
+			method:           GET,
+			route:            "/files/../users",
+			cleanPath:        PATH_REDIRECT,
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/users",
+		},
+		{
+			method:           GET,
+			route:            "/./docs",
+			trailingSlash:    PATH_REDIRECT,
+			cleanPath:        PATH_REDIRECT,
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/docs/",
+		},
+		{
+			method:         GET,
+			route:          "//docs",
+			trailingSlash:  PATH_REDIRECT,
+			cleanPath:      PATH_LENIENT,
+			expectedStatus: http.StatusMovedPermanently,
+			// Normalized path, the redirection is caused by the trailing slash
+			expectedLocation: "/docs/",
+		},
+		{
+			method:         GET,
+			route:          "/unknown/",
+			trailingSlash:  PATH_REDIRECT,
+			cleanPath:      PATH_REDIRECT,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+	}
+
+	patternHandler := func(w http.ResponseWriter, r *http.Request) {
+		pattern, _ := GetRoutePattern(r)
+		fmt.Fprintf(w, "%s %s", pattern, r.URL.Path)
+	}
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users", patternHandler)
+	mux.HandleFunc(POST, "/users", patternHandler)
+	mux.HandleFunc(GET, "/docs/", patternHandler)
+
+	// Default policies
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("/users/ got unexpected status with the default policies. expected=%d, got=%d", http.StatusOK, w.Code)
+	}
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL, _ = url.ParseRequestURI("/docs/../users")
+	mux.ServeHTTP(w, req)
+	if location := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || location != "/users" {
+		t.Errorf("/docs/../users got unexpected response with the default policies. expected=%d %q, got=%d %q", http.StatusMovedPermanently, "/users", w.Code, location)
+	}
+
+	for _, tc := range testCases {
+		mux.TrailingSlash = tc.trailingSlash
+		mux.CleanPath = tc.cleanPath
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(string(tc.method), "/", nil)
+		req.URL, _ = url.ParseRequestURI(tc.route) // Keep the path as is
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%s] %s got unexpected status. expected=%d, got=%d", tc.method, tc.route, tc.expectedStatus, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != tc.expectedLocation {
+			t.Errorf("[%s] %s got unexpected location. expected=%q, got=%q", tc.method, tc.route, tc.expectedLocation, location)
+		}
+		if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+			t.Errorf("[%s] %s got unexpected body. expected=%q, got=%q", tc.method, tc.route, tc.expectedBody, w.Body.String())
+		}
+	}
+}
+
+func TestCaseInsensitivePath(t *testing.T) {
+	testCases := []struct {
+		casing           PathPolicy
+		method           HttpMethod
+		route            string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+		expectedAllow    string
+	}{
+		{
+			casing:         PATH_STRICT,
+			method:         GET,
+			route:          "/users/Alice/profile",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			casing:         PATH_LENIENT,
+			method:         GET,
+			route:          "/users/Alice/profile",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Alice", // Parameter values keep their case
+		},
+		{
+			casing:         PATH_REDIRECT,
+			method:         GET,
+			route:          "/Users/Alice/Profile", // Exact match
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Alice",
+		},
+		{
+			casing:           PATH_REDIRECT,
+			method:           GET,
+			route:            "/USERS/Bob/PROFILE?tab=posts",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/Users/Bob/Profile?tab=posts",
+		},
+		{
+			casing:           PATH_REDIRECT,
+			method:           GET,
+			route:            "/DOCS", // Registered with a trailing slash
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/docs/",
+		},
+		{
+			casing:         PATH_LENIENT,
+			method:         POST,
+			route:          "/users/alice/profile",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET, HEAD",
+		},
+		{
+			casing:         PATH_LENIENT,
+			method:         HEAD,
+			route:          "/users/alice/profile",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			casing:         PATH_LENIENT,
+			method:         GET,
+			route:          "/%C3%A4", // "/ä" shares its first byte with "/é" and "/ö"
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			casing:         PATH_REDIRECT,
+			method:         GET,
+			route:          "/%C3%A4",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			casing:         PATH_LENIENT,
+			method:         GET,
+			route:          "/%C3%A9",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "é",
+		},
+		{
+			casing:         PATH_LENIENT,
+			method:         GET,
+			route:          "/%C3%89", // Only ASCII letters are folded
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	mux := NewHttpRouter()
+	mux.HandleHead = true
+	mux.HandleFunc(GET, "/Users/{name}/Profile", func(w http.ResponseWriter, r *http.Request) {
+		name, _ := GetRouteParam(r, "name")
+		w.Write([]byte(name))
+	})
+	mux.HandleFunc(GET, "/docs/", okHandler)
+	mux.HandleFunc(GET, "/é", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("é"))
+	})
+	mux.HandleFunc(GET, "/ö", okHandler)
+
+	for _, tc := range testCases {
+		mux.Casing = tc.casing
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(string(tc.method), tc.route, nil))
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%s] %s got unexpected status. expected=%d, got=%d", tc.method, tc.route, tc.expectedStatus, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != tc.expectedLocation {
+			t.Errorf("[%s] %s got unexpected location. expected=%q, got=%q", tc.method, tc.route, tc.expectedLocation, location)
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.expectedAllow {
+			t.Errorf("[%s] %s got unexpected Allow header. expected=%q, got=%q", tc.method, tc.route, tc.expectedAllow, allow)
+		}
+		if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+			t.Errorf("[%s] %s got unexpected body. expected=%q, got=%q", tc.method, tc.route, tc.expectedBody, w.Body.String())
+		}
+	}
+}
+
+func TestRawPath(t *testing.T) {
+	testCases := []struct {
+		useRawPath       bool
+		route            string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			useRawPath:     false,
+			route:          "/files/a%2Fb",
+			expectedStatus: http.StatusNotFound, // Routed as "/files/a/b"
+		},
+		{
+			useRawPath:     true,
+			route:          "/files/a%2Fb",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "a/b",
+		},
+		{
+			useRawPath:     true,
+			route:          "/files/what%3F",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "what?",
+		},
+		{
+			useRawPath:     true,
+			route:          "/files/caf%C3%A9",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "café",
+		},
+		{
+			useRawPath:     true,
+			route:          "/paths/a%2Fb", // Constraint matched against the unescaped value
+			expectedStatus: http.StatusOK,
+			expectedBody:   "a/b",
+		},
+		{
+			useRawPath:     true,
+			route:          "/paths/a%2F1",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			useRawPath:     true,
+			route:          "/static/a%2Fb/c",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/a/b/c /a%2Fb/c", // Stripped path, kept escaped
+		},
+		{
+			useRawPath:       true,
+			route:            "/files/a%2Fb/",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/files/a%2Fb",
+		},
+		{
+			useRawPath:     true,
+			route:          "/files/50%25",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "50%",
+		},
+		{
+			useRawPath:     true,
+			route:          "/caf%C3%A9/a%2Fb", // Escaped static part
+			expectedStatus: http.StatusOK,
+			expectedBody:   "a/b",
+		},
+		{
+			useRawPath:     false,
+			route:          "/caf%C3%A9/b",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "b",
+		},
+		{
+			useRawPath:       true,
+			route:            "/caf%C3%A9/a%2Fb/",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/caf%C3%A9/a%2Fb",
+		},
+		{
+			useRawPath:     true,
+			route:          "/static/caf%C3%A9/a%2Fb",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/café/a/b /caf%C3%A9/a%2Fb",
+		},
+	}
+
+	mux := NewHttpRouter()
+	mux.TrailingSlash = PATH_REDIRECT
+	mux.HandleFunc(GET, "/files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name, _ := GetRouteParam(r, "name")
+		w.Write([]byte(name))
+	})
+	mux.HandleFunc(GET, "/paths/{path:[a-z/]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("path")))
+	})
+	mux.HandleFunc(GET, "/café/{item}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("item")))
+	})
+	mux.Mount("/static", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.URL.RawPath))
+	}), WithStripPrefix())
+
+	for _, tc := range testCases {
+		mux.UseRawPath = tc.useRawPath
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tc.route, nil))
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("%s got unexpected status. expected=%d, got=%d", tc.route, tc.expectedStatus, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != tc.expectedLocation {
+			t.Errorf("%s got unexpected location. expected=%q, got=%q", tc.route, tc.expectedLocation, location)
+		}
+		if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+			t.Errorf("%s got unexpected body. expected=%q, got=%q", tc.route, tc.expectedBody, w.Body.String())
+		}
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	testCases := []struct {
+		method         HttpMethod
+		host           string
+		route          string
+		expectedStatus int
+		expectedBody   string
+		expectedAllow  string
+	}{
+		{
+			method:         GET,
+			host:           "acme.example.com",
+			route:          "/dashboard",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "tenant acme",
+		},
+		{
+			method:         GET,
+			host:           "Acme.Example.com:8080", // Port and case ignored
+			route:          "/dashboard",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "tenant acme",
+		},
+		{
+			method:         GET,
+			host:           "www.example.com", // Exact host favored
+			route:          "/dashboard",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "www",
+		},
+		{
+			method:         GET,
+			host:           "eu.acme.example.com",
+			route:          "/dashboard",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "region eu tenant acme",
+		},
+		{
+			method:         GET,
+			host:           "a.b.c.example.com", // Wildcards span a single label
+			route:          "/dashboard",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "default",
+		},
+		{
+			method:         GET,
+			host:           "acme.example.com",
+			route:          "/users/42",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "acme 42",
+		},
+		{
+			method:         GET,
+			host:           "acme.example.com",
+			route:          "/health", // Routes of every host as fallback
+			expectedStatus: http.StatusOK,
+			expectedBody:   "default",
+		},
+		{
+			method:         POST,
+			host:           "acme.example.com",
+			route:          "/dashboard",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET, DELETE",
+		},
+		{
+			method:         GET,
+			host:           "42.api.example.com",
+			route:          "/status",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "version 42",
+		},
+		{
+			method:         GET,
+			host:           "v2.api.example.com", // Host constraint mismatch
+			route:          "/status",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	writeParams := func(names ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var values []string
+			for _, name := range names {
+				value, _ := GetRouteParam(r, name)
+				values = append(values, name+" "+value)
+			}
+			w.Write([]byte(strings.Join(values, " ")))
+		}
+	}
+	writeBody := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/dashboard", writeBody("default"))
+	mux.HandleFunc(GET, "/health", writeBody("default"))
+	mux.HandleFunc(DELETE, "/dashboard", okHandler)
+	mux.HandleFunc(GET, "/dashboard", writeBody("www"), WithHost("www.example.com"))
+	tenants := mux.Host("{tenant}.example.com")
+	tenants.HandleFunc(GET, "/dashboard", writeParams("tenant"))
+	tenants.HandleFunc(GET, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("tenant") + " " + r.PathValue("id")))
+	})
+	mux.Host("{region}.{tenant}.example.com").HandleFunc(GET, "/dashboard", writeParams("region", "tenant"))
+	mux.Host("{version:int}.api.example.com").HandleFunc(GET, "/status", writeParams("version"))
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(string(tc.method), tc.route, nil)
+		req.Host = tc.host
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%s] %s%s got unexpected status. expected=%d, got=%d", tc.method, tc.host, tc.route, tc.expectedStatus, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.expectedAllow {
+			t.Errorf("[%s] %s%s got unexpected Allow header. expected=%q, got=%q", tc.method, tc.host, tc.route, tc.expectedAllow, allow)
+		}
+		if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+			t.Errorf("[%s] %s%s got unexpected body. expected=%q, got=%q", tc.method, tc.host, tc.route, tc.expectedBody, w.Body.String())
+		}
+	}
+}
+
+func TestHostRegistrationErrors(t *testing.T) {
+	testCases := []struct {
+		host           string
+		route          string
+		expectedOffset int // -1 when the error isn't a syntax error
+	}{
+		{
+			host:           "api..example.com",
+			route:          "/users",
+			expectedOffset: 4,
+		},
+		{
+			host:           "api-{version}.example.com",
+			route:          "/users",
+			expectedOffset: 0,
+		},
+		{
+			host:           "{tenant}.{tenant}.example.com",
+			route:          "/users",
+			expectedOffset: 9,
+		},
+		{
+			host:           "{tenant:[a-z}.example.com",
+			route:          "/users",
+			expectedOffset: 8, // Constraint
+		},
+		{
+			host:           "{tenant}.example.com",
+			route:          "/users/{tenant}",
+			expectedOffset: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		mux := NewHttpRouter()
+		err := mux.TryHandleFunc(GET, tc.route, okHandler, WithHost(tc.host))
+		var registrationErr *RegistrationError
+		if !errors.As(err, &registrationErr) || !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("%s%s expected invalid pattern error, got=%v", tc.host, tc.route, err)
+			continue
+		}
+
+		var syntaxErr *SyntaxError
+		if tc.expectedOffset == -1 {
+			if errors.As(err, &syntaxErr) {
+				t.Errorf("%s%s got unexpected syntax error: %v", tc.host, tc.route, err)
+			}
+		} else if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s%s expected syntax error, got=%v", tc.host, tc.route, err)
+		} else if syntaxErr.Offset != tc.expectedOffset {
+			t.Errorf("%s%s got unexpected syntax error offset. expected=%d, got=%d", tc.host, tc.route, tc.expectedOffset, syntaxErr.Offset)
+		}
+	}
+}
+
+func TestRoutePredicates(t *testing.T) {
+	testCases := []struct {
+		method         HttpMethod
+		route          string
+		header         http.Header
+		expectedStatus int
+		expectedBody   string
+		expectedAllow  string
+	}{
+		{
+			method:         GET,
+			route:          "/items",
+			header:         http.Header{"Accept": {"application/vnd.api.v2+json"}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "v2",
+		},
+		{
+			method:         GET,
+			route:          "/items",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "v1",
+		},
+		{
+			method:         GET,
+			route:          "/orders",
+			header:         http.Header{"X-Api-Version": {"2"}},
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "POST",
+		},
+		{
+			method:         GET,
+			route:          "/orders", // Predicates of the other methods routes aren't evaluated
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "POST",
+		},
+		{
+			method:         POST,
+			route:          "/reports",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "GET",
+		},
+		{
+			method:         GET,
+			route:          "/reports", // Own method predicates not matched
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	writeBody := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/items", writeBody("v1"))
+	mux.HandleFunc(GET, "/items", writeBody("v2"), WithPredicates(MatchAccept("application/vnd.api.v2+json")))
+	mux.HandleFunc(POST, "/orders", okHandler, WithPredicates(MatchHeader("X-Api-Version", "2")))
+	mux.HandleFunc(GET, "/reports", okHandler, WithPredicates(MatchHeader("X-Api-Version", "2")))
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(string(tc.method), tc.route, nil)
+		for name, values := range tc.header {
+			req.Header[name] = values
+		}
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%s] %s got unexpected status. expected=%d, got=%d", tc.method, tc.route, tc.expectedStatus, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.expectedAllow {
+			t.Errorf("[%s] %s got unexpected Allow header. expected=%q, got=%q", tc.method, tc.route, tc.expectedAllow, allow)
+		}
+		if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+			t.Errorf("[%s] %s got unexpected body. expected=%q, got=%q", tc.method, tc.route, tc.expectedBody, w.Body.String())
+		}
+	}
+
+	err := mux.TryHandleFunc(GET, "/items", okHandler, WithPredicates(MatchAccept("application/vnd.api.v2+json")))
+	if !errors.Is(err, ErrDuplicateRoute) {
+		t.Errorf("expected duplicate route error, got=%v", err)
+	}
+	if routes := mux.Routes(); !slices.Equal(routes[1].Predicates, []string{"accept application/vnd.api.v2+json"}) {
+		t.Errorf("got unexpected route predicates. expected=%q, got=%q", "accept application/vnd.api.v2+json", routes[1].Predicates)
+	}
+}
+
+func traceMiddleware(name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func BenchmarkServeHTTPWithMiddleware(b *testing.B) {
+	passthrough := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+		})
+	}
+	mux := NewHttpRouter()
+	mux.UseMiddlewares(passthrough, passthrough, passthrough)
+	mux.HandleFunc(GET, "/users/{id}", okHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	initialReq := *req
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		*req = initialReq // ServeHTTP updates the request context
+		mux.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServeHTTPStatic(b *testing.B) {
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users", okHandler)
+	mux.HandleFunc(GET, "/users/{id}", okHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users?page=2", nil)
+	initialReq := *req
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		*req = initialReq // ServeHTTP updates the request context
+		mux.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServeHTTPParams(b *testing.B) {
+	mux := NewHttpRouter()
+	mux.HandleFunc(GET, "/users/{id}/posts/{post}", okHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users/42/posts/7", nil)
+	initialReq := *req
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		*req = initialReq // ServeHTTP updates the request context
+		mux.ServeHTTP(w, req)
+	}
+}
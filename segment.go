@@ -0,0 +1,89 @@
+package router
+
+import "fmt"
+
+// Static or wildcard part of a route. Static parts include the segments separators.
+type routePart struct {
+	literal    string
+	name       string // Wildcard name, empty for static parts
+	catchAll   bool   // Wildcard capturing the remainder of the path
+	constraint *paramConstraint
+}
+
+// Convert each pattern segment to route parts, resolving wildcard constraints
+func (t *tree) segmentsParts(pattern *Pattern) ([][]routePart, error) {
+	segments := make([][]routePart, len(pattern.Segments))
+	for i, segment := range pattern.Segments {
+		parts := []routePart{{literal: "/"}}
+		for _, node := range segment.Nodes {
+			switch n := node.(type) {
+			case *Literal:
+				parts = appendRoutePart(parts, routePart{literal: n.Value})
+			case *Param:
+				constraint, err := t.resolveConstraint(pattern, n.Constraint)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, routePart{name: n.Name, constraint: constraint})
+			case *CatchAll:
+				constraint, err := t.resolveConstraint(pattern, n.Constraint)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, routePart{name: n.Name, catchAll: true, constraint: constraint})
+			}
+		}
+		segments[i] = parts
+	}
+	return segments, nil
+}
+
+// Route parts of the path made of the given segments, the root path is made of a single separator
+func joinSegmentsParts(segments [][]routePart, trailingSlash bool) []routePart {
+	if len(segments) == 0 {
+		return []routePart{{literal: "/"}}
+	}
+
+	var parts []routePart
+	for _, segment := range segments {
+		for _, part := range segment {
+			parts = appendRoutePart(parts, part)
+		}
+	}
+	if trailingSlash {
+		parts = appendRoutePart(parts, routePart{literal: "/"})
+	}
+	return parts
+}
+
+// Append the part, merging adjacent static parts
+func appendRoutePart(parts []routePart, part routePart) []routePart {
+	if part.name == "" && len(parts) != 0 && parts[len(parts)-1].name == "" {
+		parts[len(parts)-1].literal += part.literal
+		return parts
+	}
+	return append(parts, part)
+}
+
+// Optional segments are made of a single optional wildcard
+func isOptionalSegment(segment Segment) bool {
+	switch node := segment.Nodes[0].(type) {
+	case *Param:
+		return node.Optional
+	case *CatchAll:
+		return node.Optional
+	}
+	return false
+}
+
+// Compile the constraint, an invalid one is reported as a *SyntaxError
+func (t *tree) resolveConstraint(pattern *Pattern, constraint *Constraint) (*paramConstraint, error) {
+	if constraint == nil {
+		return nil, nil
+	}
+	compiled, err := t.compileConstraint(constraint.Expr)
+	if err != nil {
+		return nil, &SyntaxError{Pattern: pattern.Raw, Offset: constraint.Offset, Msg: fmt.Sprintf("invalid wildcard constraint: %v", err)}
+	}
+	return compiled, nil
+}
@@ -5,22 +5,49 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 )
 
 const (
-	GET    HttpMethod = "GET"
-	POST   HttpMethod = "POST"
-	PUT    HttpMethod = "PUT"
-	PATCH  HttpMethod = "PATCH"
-	DELETE HttpMethod = "DELETE"
-
-	WILDCARD_START_CHAR byte = '{'
+	GET     HttpMethod = "GET"
+	POST    HttpMethod = "POST"
+	PUT     HttpMethod = "PUT"
+	PATCH   HttpMethod = "PATCH"
+	DELETE  HttpMethod = "DELETE"
+	HEAD    HttpMethod = "HEAD"
+	OPTIONS HttpMethod = "OPTIONS"
+	CONNECT HttpMethod = "CONNECT"
+	TRACE   HttpMethod = "TRACE"
+
+	WILDCARD_START_CHAR  byte   = '{'
+	WILDCARD_END_CHAR    byte   = '}'
+	CATCH_ALL_START_CHAR byte   = '*'
+	CATCH_ALL_SUFFIX     string = "..."
+	CONSTRAINT_SEPARATOR byte   = ':'
+	OPTIONAL_SUFFIX      string = "?"
+
+	// Method reported for mounted handlers, which accept every method
+	MOUNT_METHOD HttpMethod = "*"
 )
 
+// Name of the catch-all capturing the path below mount prefixes, not a valid pattern name
+const mountParam string = "*"
+
+// Methods with a preallocated root node, in lookup order
+var commonMethods = [...]HttpMethod{GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS}
+
 var (
-	ErrUnhandledMethod error = errors.New("unhandled method")
-	ErrNotFound        error = errors.New("not found")
+	ErrUnhandledMethod  error = errors.New("unhandled method")
+	ErrNotFound         error = errors.New("not found")
+	ErrMethodNotAllowed error = errors.New("method not allowed")
+
+	ErrUnsupportedMethod   error = errors.New("HTTP method is not supported")
+	ErrInvalidPattern      error = errors.New("invalid route pattern")
+	ErrDuplicateRoute      error = errors.New("route was already registered with another handler on the same HTTP method")
+	ErrConflictingWildcard error = errors.New("wildcard parameter conflicts with an already registered one")
+	ErrDuplicateRouteName  error = errors.New("route name was already registered")
 )
 
 var splitFn = func(c rune) bool {
@@ -29,97 +56,229 @@ var splitFn = func(c rune) bool {
 
 type HttpMethod string
 
+// Route registration failure. The cause can be checked with errors.Is against ErrUnsupportedMethod, ErrInvalidPattern,
+// ErrDuplicateRoute, ErrConflictingWildcard and ErrDuplicateRouteName, invalid patterns are detailed by a *SyntaxError.
+type RegistrationError struct {
+	Method HttpMethod
+	Route  string
+	Err    error
+}
+
+func (e *RegistrationError) Error() string {
+	return fmt.Sprintf("[%s] %s %v", e.Method, e.Route, e.Err)
+}
+
+func (e *RegistrationError) Unwrap() error {
+	return e.Err
+}
+
 type routeData struct {
-	Handler http.Handler
-	Context requestContext
+	Handler        http.Handler
+	AllowedMethods []HttpMethod // Methods handling the route, set along ErrMethodNotAllowed
 }
 
 type tree struct {
-	nodes [5]treeNode
+	nodes      [len(commonMethods)]treeNode // Roots of the common methods, indexed as commonMethods
+	extraNodes map[HttpMethod]*treeNode     // Roots of any other method, created on registration
+	converters map[string]ParamConverter    // Named converters usable as wildcard constraints
+	mounts     treeNode                     // Root of the handlers mounted for every method
 }
 
-type routePart struct {
-	route    string
-	wildcard bool
+// Wildcard value constraint, either a regular expression or a converter
+type paramConstraint struct {
+	expr      string // Source of the constraint, identifying it
+	regexp    *regexp.Regexp
+	converter ParamConverter
 }
 
 func NewTree() *tree {
-	return &tree{
-		[5]treeNode{
-			{Content: string(GET), Children: make(map[string]*treeNode), WildCardChildren: []*treeNode{}},
-			{Content: string(POST), Children: make(map[string]*treeNode), WildCardChildren: []*treeNode{}},
-			{Content: string(PUT), Children: make(map[string]*treeNode), WildCardChildren: []*treeNode{}},
-			{Content: string(PATCH), Children: make(map[string]*treeNode), WildCardChildren: []*treeNode{}},
-			{Content: string(DELETE), Children: make(map[string]*treeNode), WildCardChildren: []*treeNode{}},
-		},
+	t := &tree{
+		extraNodes: make(map[HttpMethod]*treeNode),
+		converters: defaultConverters(),
+		mounts:     newRootNode(MOUNT_METHOD),
 	}
+	for i, method := range commonMethods {
+		t.nodes[i] = newRootNode(method)
+	}
+	return t
 }
 
-// Can panic
-func (t *tree) Register(method HttpMethod, route string, handler http.Handler) {
-	root, found := t.GetRootNode(method)
-	if !found {
-		panic(fmt.Sprintf("%s HTTP method is not supported", method))
+// Register the handler for the route, errors are of type *RegistrationError
+// Routes with predicates are only served when the request matches all of them, see Predicate.
+func (t *tree) Register(method HttpMethod, route string, handler http.Handler, predicates ...Predicate) error {
+	if !isValidMethod(method) {
+		return &RegistrationError{method, route, ErrUnsupportedMethod}
 	}
 
-	routeSplit := strings.FieldsFunc(route, splitFn)
-	if len(routeSplit) == 0 {
-		// Root path
-		if root.Handler == nil {
-			root.Handler = handler
-			return
-		} else {
-			panic(fmt.Sprintf("[%s] %s was already registered with another handler", method, route))
-		}
+	pattern, err := ParsePattern(route)
+	if err != nil {
+		return &RegistrationError{method, route, err}
 	}
+	if err := t.register(t.getOrCreateRootNode(method), pattern, handler, predicates); err != nil {
+		return &RegistrationError{method, route, err}
+	}
+	return nil
+}
 
-	// Flag wildcard parameters and check potential duplication
-	routeMembers := make([]routePart, len(routeSplit))
-	wildcards := make(map[string]struct{})
-	for i, item := range routeSplit {
-		if item[0] != WILDCARD_START_CHAR {
-			routeMembers[i] = routePart{item, false}
-			continue
+// Register a handler for every method, matching the prefix and every path below it.
+// The path remainder is provided by requestContext.MountPath, errors are of type *RegistrationError.
+func (t *tree) RegisterMount(prefix string, handler http.Handler, predicates ...Predicate) error {
+	pattern, err := ParsePattern(prefix)
+	if err != nil {
+		return &RegistrationError{MOUNT_METHOD, prefix, err}
+	}
+	for _, segment := range pattern.Segments {
+		for _, node := range segment.Nodes {
+			var err error
+			switch n := node.(type) {
+			case *CatchAll:
+				err = &SyntaxError{Pattern: prefix, Offset: n.Offset, Msg: "catch-all parameter can't be used in a mount prefix"}
+			case *Param:
+				if n.Optional {
+					err = &SyntaxError{Pattern: prefix, Offset: n.Offset, Msg: "optional parameter can't be used in a mount prefix"}
+				}
+			}
+			if err != nil {
+				return &RegistrationError{MOUNT_METHOD, prefix, err}
+			}
 		}
+	}
 
-		// Handle wildcard
-		if _, found := wildcards[item]; found {
-			panic(fmt.Sprintf("[%s] %s found duplicated wildcard parameter name: %s", method, route, item))
-		}
-		wildcards[item] = struct{}{}
-		// Remove '{' & '}'
-		routeMembers[i] = routePart{item[1 : len(item)-1], true}
+	segments, err := t.segmentsParts(pattern)
+	if err != nil {
+		return &RegistrationError{MOUNT_METHOD, prefix, err}
+	}
+
+	// Match the prefix with and without trailing slash, and the paths below it with a catch-all.
+	// The catch-all name can't conflict with the ones of the pattern.
+	variants := [][]routePart{joinSegmentsParts(segments, false)}
+	if len(segments) != 0 {
+		variants = append(variants, joinSegmentsParts(segments, true))
 	}
+	remainder := []routePart{{literal: "/"}, {name: mountParam, catchAll: true}}
+	variants = append(variants, joinSegmentsParts(append(segments, remainder), false))
+	if err := registerVariants(&t.mounts, variants, handler, predicates); err != nil {
+		return &RegistrationError{MOUNT_METHOD, prefix, err}
+	}
+	return nil
+}
 
-	err := root.Register(routeMembers, 0, handler)
+func (t *tree) register(root *treeNode, pattern *Pattern, handler http.Handler, predicates []Predicate) error {
+	segments, err := t.segmentsParts(pattern)
 	if err != nil {
-		panic(fmt.Sprintf("[%s] %s %v", method, route, err))
+		return err
+	}
+
+	// Number of segments before the optional ones
+	requiredCount := slices.IndexFunc(pattern.Segments, isOptionalSegment)
+	if requiredCount == -1 {
+		requiredCount = len(segments)
+	}
+
+	// The route and each of its variants without optional trailing segments
+	variants := make([][]routePart, 0, len(segments)-requiredCount+1)
+	for length := requiredCount; length <= len(segments); length++ {
+		variants = append(variants, joinSegmentsParts(segments[:length], pattern.TrailingSlash))
 	}
+	return registerVariants(root, variants, handler, predicates)
 }
 
-func (t *tree) Find(method HttpMethod, url *url.URL) (routeData, error) {
-	root, found := t.GetRootNode(method)
-	if !found {
+// Register the handler for each variant, none is registered on failure
+func registerVariants(root *treeNode, variants [][]routePart, handler http.Handler, predicates []Predicate) error {
+	registeredNodes := make([]*treeNode, 0, len(variants))
+	for _, variant := range variants {
+		node, err := root.Register(variant, handler, predicates)
+		if err != nil {
+			// Rollback the variants already registered, the handler is the last one of their node
+			for _, registeredNode := range registeredNodes {
+				if len(predicates) == 0 {
+					registeredNode.Handler = nil
+				} else {
+					registeredNode.Predicated = registeredNode.Predicated[:len(registeredNode.Predicated)-1]
+				}
+			}
+			return err
+		}
+		registeredNodes = append(registeredNodes, node)
+	}
+	return nil
+}
+
+// Find the handler of the path, the route parameters and mount path are stored in the request context
+func (t *tree) Find(method HttpMethod, path string, rCtx *requestContext) (routeData, error) {
+	return t.find(method, path, rCtx, nil)
+}
+
+// Find the handler of the path comparing the static parts of the routes case-insensitively.
+// Return the path with the casing of the matched route, parameter values keep their original case.
+func (t *tree) FindCaseInsensitive(method HttpMethod, path string, rCtx *requestContext) (routeData, string, error) {
+	canonical := make([]byte, 0, len(path))
+	routeData, err := t.find(method, path, rCtx, &canonical)
+	return routeData, string(canonical), err
+}
+
+// Search the path, case-insensitively when the canonical path is set
+func (t *tree) find(method HttpMethod, path string, rCtx *requestContext, canonical *[]byte) (routeData, error) {
+	if !isValidMethod(method) {
 		return routeData{}, ErrUnhandledMethod
 	}
 
-	routeSplit := strings.FieldsFunc(url.Path, splitFn)
-	if len(routeSplit) == 0 {
-		// Root path
-		if root.Handler != nil {
-			return routeData{Handler: root.Handler}, nil
-		} else {
-			return routeData{}, ErrNotFound
+	search := nodeSearch{params: &rCtx.params, canonical: canonical, unescape: rCtx.escapedPath, request: rCtx.request}
+	if root, found := t.GetRootNode(method); found {
+		if _, found := root.find(path, &search); found {
+			return routeData{Handler: search.handler}, nil
 		}
 	}
 
-	routeParams := map[string]string{}
-	node, found := root.Find(routeSplit, 0, routeParams)
-	if !found {
-		return routeData{}, ErrNotFound
+	// Mounted handlers accept every method
+	if _, found := t.mounts.find(path, &search); found {
+		remainder, _ := rCtx.removeParam(mountParam)
+		rCtx.MountPath = "/" + remainder
+		return routeData{Handler: search.handler}, nil
 	}
 
-	return routeData{node.Handler, requestContext{routeParams, url.Query()}}, nil
+	// Check if the route is handled by other methods
+	if allowed := t.methods(path, method, search); len(allowed) != 0 {
+		return routeData{AllowedMethods: allowed}, ErrMethodNotAllowed
+	}
+	return routeData{}, ErrNotFound
+}
+
+// Retrieve all the methods having a handler registered for the path, in a stable order: common methods first,
+// then others sorted by name.
+// The search options are used, its results are discarded. Route predicates are only evaluated for the requested
+// method: the routes of the other methods are allowed whatever the request.
+func (t *tree) methods(path string, requested HttpMethod, search nodeSearch) []HttpMethod {
+	search.params = &[]routeParam{}
+	if search.canonical != nil {
+		search.canonical = &[]byte{}
+	}
+	request := search.request
+	found := func(method HttpMethod, root *treeNode) bool {
+		search.request = nil
+		if method == requested {
+			search.request = request
+		}
+		_, found := root.find(path, &search)
+		return found
+	}
+
+	var methods []HttpMethod
+	for i := range t.nodes {
+		if found(commonMethods[i], &t.nodes[i]) {
+			methods = append(methods, commonMethods[i])
+		}
+	}
+
+	var extraMethods []HttpMethod
+	for method, root := range t.extraNodes {
+		if found(method, root) {
+			extraMethods = append(extraMethods, method)
+		}
+	}
+	slices.Sort(extraMethods)
+
+	return append(methods, extraMethods...)
 }
 
 func (t *tree) GetRootNode(method HttpMethod) (*treeNode, bool) {
@@ -134,98 +293,402 @@ func (t *tree) GetRootNode(method HttpMethod) (*treeNode, bool) {
 		return &t.nodes[3], true
 	case DELETE:
 		return &t.nodes[4], true
+	case HEAD:
+		return &t.nodes[5], true
+	case OPTIONS:
+		return &t.nodes[6], true
 	}
 
-	return nil, false
+	root, found := t.extraNodes[method]
+	return root, found
+}
+
+// Get the root node of the given method, creating it if it doesn't exist yet
+func (t *tree) getOrCreateRootNode(method HttpMethod) *treeNode {
+	if root, found := t.GetRootNode(method); found {
+		return root
+	}
+
+	root := newRootNode(method)
+	t.extraNodes[method] = &root
+	return &root
+}
+
+func newRootNode(method HttpMethod) treeNode {
+	return treeNode{Content: string(method)}
+}
+
+// Check that the method is a valid HTTP token (RFC 9110, section 5.6.2)
+func isValidMethod(method HttpMethod) bool {
+	if len(method) == 0 {
+		return false
+	}
+	for i := 0; i < len(method); i++ {
+		if !isTokenChar(method[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
+}
+
+// Register a named converter, usable by the routes registered afterwards with the "{name:converter}" syntax
+func (t *tree) RegisterConverter(name string, converter ParamConverter) {
+	t.converters[name] = converter
+}
+
+// Resolve a wildcard constraint: a named converter, or else a regular expression which must match the whole parameter value.
+// Plain identifiers must be registered converter names, to report typos and converters registered after the route.
+func (t *tree) compileConstraint(expr string) (*paramConstraint, error) {
+	if expr == "" {
+		return nil, errors.New("empty constraint")
+	}
+	if converter, found := t.converters[expr]; found {
+		return &paramConstraint{expr: expr, converter: converter}, nil
+	}
+	if isIdentifier(expr) {
+		return nil, fmt.Errorf("unknown converter: %s", expr)
+	}
+
+	regexp, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &paramConstraint{expr: expr, regexp: regexp}, nil
 }
 
+// Converter names are made of letters, digits and underscores, and don't start with a digit
+func isIdentifier(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !(i != 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Check the value against the constraint, return the converted value when using a converter
+func (c *paramConstraint) match(value string) (any, bool) {
+	if c == nil {
+		return nil, true
+	}
+	if c.converter != nil {
+		converted, err := c.converter(value)
+		return converted, err == nil
+	}
+	return nil, c.regexp.MatchString(value)
+}
+
+// Source of the constraint, empty when there is none
+func (c *paramConstraint) String() string {
+	if c == nil {
+		return ""
+	}
+	return c.expr
+}
+
+// Node of a prefix-compressed radix tree. Static edges are byte sequences, wildcards and catch-alls have their own nodes.
 type treeNode struct {
-	Content          string
-	Handler          http.Handler
-	Children         map[string]*treeNode
-	WildCardChildren []*treeNode
-}
-
-// Can panic
-func (node *treeNode) Register(route []routePart, currentIndex int, handler http.Handler) error {
-	var currentNode *treeNode
-	var isWildcard bool
-	if !route[currentIndex].wildcard {
-		// Normal node
-		currentNode = node.Children[route[currentIndex].route]
-	} else {
-		// Wildcard node
-		for _, wilcardNode := range node.WildCardChildren {
-			if wilcardNode.Content == route[currentIndex].route {
-				currentNode = wilcardNode
-				break
+	Content          string              // Edge of static nodes, name of wildcard nodes
+	Handler          http.Handler        // Handler of the route without predicates
+	Predicated       []predicatedHandler // Handlers of the routes with predicates, favored in registration order
+	Indices          string              // First byte of each static child edge, in Children order
+	Children         []*treeNode         // Static children, edges start with different bytes
+	WildCardChildren []*treeNode         // Constrained wildcards are placed before unconstrained ones
+	CatchAllChild    *treeNode           // Lowest priority child, matching all the remaining route segments
+	Constraint       *paramConstraint    // Wildcard value constraint, nil when unconstrained
+}
+
+// Register the handler on the node matching the route parts, return this node.
+// Routes with predicates only conflict with the ones having the same predicates.
+func (node *treeNode) Register(route []routePart, handler http.Handler, predicates []Predicate) (*treeNode, error) {
+	if len(route) == 0 {
+		// Final node
+		if len(predicates) != 0 {
+			key := predicatesKey(predicates)
+			for _, predicated := range node.Predicated {
+				if predicatesKey(predicated.Predicates) == key {
+					return nil, fmt.Errorf("%w: same predicates %s", ErrDuplicateRoute, key)
+				}
 			}
+			node.Predicated = append(node.Predicated, predicatedHandler{predicates, handler})
+			return node, nil
+		}
+		if node.Handler != nil {
+			return nil, ErrDuplicateRoute
 		}
-		isWildcard = true
+		node.Handler = handler
+		return node, nil
 	}
 
-	if currentNode == nil {
-		// New node
-		currentNode = &treeNode{
-			Content:          route[currentIndex].route,
-			Children:         make(map[string]*treeNode),
-			WildCardChildren: []*treeNode{},
+	part := route[0]
+	if part.catchAll {
+		// Catch-all node, only one per node
+		if node.CatchAllChild == nil {
+			node.CatchAllChild = &treeNode{Content: part.name, Constraint: part.constraint}
+		} else if node.CatchAllChild.Content != part.name || node.CatchAllChild.Constraint.String() != part.constraint.String() {
+			return nil, fmt.Errorf("%w: catch-all parameter %s conflicts with catch-all parameter %s", ErrConflictingWildcard, part.name, node.CatchAllChild.Content)
 		}
+		return node.CatchAllChild.Register(route[1:], handler, predicates)
+	}
 
-		if isWildcard {
-			node.WildCardChildren = append(node.WildCardChildren, currentNode)
-		} else {
-			node.Children[route[currentIndex].route] = currentNode
+	if part.name != "" {
+		// Wildcard node, shared by the routes using the same name and constraint
+		for _, wildcardNode := range node.WildCardChildren {
+			if wildcardNode.Content == part.name && wildcardNode.Constraint.String() == part.constraint.String() {
+				return wildcardNode.Register(route[1:], handler, predicates)
+			}
 		}
+		wildcardNode := &treeNode{Content: part.name, Constraint: part.constraint}
+		node.addWildcardChild(wildcardNode)
+		return wildcardNode.Register(route[1:], handler, predicates)
+	}
 
-		if currentIndex == len(route)-1 {
-			// Register handler on final node
-			currentNode.Handler = handler
-			return nil
-		}
-	} else if currentIndex == len(route)-1 {
-		// Last node exists
-		if currentNode.Handler == nil {
-			// Register handler on final node
-			currentNode.Handler = handler
-			return nil
+	// Static node
+	index := strings.IndexByte(node.Indices, part.literal[0])
+	if index == -1 {
+		child := &treeNode{Content: part.literal}
+		node.Indices += part.literal[:1]
+		node.Children = append(node.Children, child)
+		return child.Register(route[1:], handler, predicates)
+	}
+
+	child := node.Children[index]
+	common := commonPrefixLength(child.Content, part.literal)
+	if common < len(child.Content) {
+		// Split the edge: a new node holds the common prefix, the child keeps its data with the remainder
+		prefixNode := &treeNode{Content: child.Content[:common], Indices: child.Content[common : common+1], Children: []*treeNode{child}}
+		child.Content = child.Content[common:]
+		node.Children[index] = prefixNode
+		child = prefixNode
+	}
+	if common < len(part.literal) {
+		// Continue with the remainder of the literal
+		remainder := slices.Clone(route)
+		remainder[0].literal = part.literal[common:]
+		return child.Register(remainder, handler, predicates)
+	}
+	return child.Register(route[1:], handler, predicates)
+}
+
+// Insert a wildcard child, after the existing children having the same priority
+func (node *treeNode) addWildcardChild(child *treeNode) {
+	index := len(node.WildCardChildren)
+	if child.Constraint != nil {
+		// Insert before the first unconstrained wildcard
+		index = slices.IndexFunc(node.WildCardChildren, func(n *treeNode) bool {
+			return n.Constraint == nil
+		})
+		if index == -1 {
+			index = len(node.WildCardChildren)
 		}
+	}
+	node.WildCardChildren = slices.Insert(node.WildCardChildren, index, child)
+}
 
-		// Handler already registered on this node: panic
-		return errors.New("route was already registered with another handler on the same HTTP method")
+// Handler serving the request: the first route whose predicates match, in registration order,
+// else the route without predicates. Without request, predicates are not evaluated.
+func (node *treeNode) handler(r *http.Request) http.Handler {
+	for i := range node.Predicated {
+		if node.Predicated[i].match(r) {
+			return node.Predicated[i].Handler
+		}
 	}
+	return node.Handler
+}
 
-	return currentNode.Register(route, currentIndex+1, handler)
+func (node *treeNode) hasHandler() bool {
+	return node.Handler != nil || len(node.Predicated) != 0
 }
 
-func (node *treeNode) Find(route []string, currentIndex int, routeParams map[string]string) (*treeNode, bool) {
-	if currentIndex == len(route) {
-		// Last index: try find handler
-		if node.Handler != nil {
+// Check that the wildcard node accepts the given value, return the converted value when using a converter
+func (node *treeNode) matchConstraint(value string) (any, bool) {
+	return node.Constraint.match(value)
+}
+
+// Options and results of a node search
+type nodeSearch struct {
+	params    *[]routeParam // Captured parameters, appended only when the node is found
+	canonical *[]byte       // Set for case-insensitive searches, receives the path with the casing of the static edges
+	unescape  bool          // The path is escaped: parameter values are unescaped before matching their constraint
+	request   *http.Request // Request evaluated by the route predicates, predicates are not evaluated when nil
+	handler   http.Handler  // Handler selected on the found node
+}
+
+// Search the node, comparing static edges case-insensitively when the canonical path is set.
+// The matched parts of the path are then appended to it, with the casing of the static edges.
+func (node *treeNode) find(path string, search *nodeSearch) (*treeNode, bool) {
+	if path == "" {
+		// End of path: try find handler
+		if handler := node.handler(search.request); handler != nil {
+			search.handler = handler
 			return node, true
 		} else {
 			return nil, false
 		}
 	}
 
-	// Try find matching children
-	if n, found := node.Children[route[currentIndex]]; found {
-		foundNode, found := n.Find(route, currentIndex+1, routeParams) // Recursive find on matching node
-		if found {
-			return foundNode, true
+	if search.canonical == nil {
+		// Try find matching static child
+		if index := strings.IndexByte(node.Indices, path[0]); index != -1 {
+			child := node.Children[index]
+			if strings.HasPrefix(path, child.Content) {
+				foundNode, found := child.find(path[len(child.Content):], search) // Recursive find on matching node
+				if found {
+					return foundNode, true
+				}
+			}
+		}
+	} else {
+		// Try find static children matching regardless of the case
+		for _, child := range node.Children {
+			if len(path) < len(child.Content) || !equalFoldASCII(path[:len(child.Content)], child.Content) {
+				continue
+			}
+			foundNode, found := child.findAfter(child.Content, path[len(child.Content):], search)
+			if found {
+				return foundNode, true
+			}
+		}
+	}
+
+	if len(node.WildCardChildren) != 0 {
+		segmentEnd := strings.IndexByte(path, '/')
+		if segmentEnd == -1 {
+			segmentEnd = len(path)
+		}
+
+		// No matching static child: try wildcards followed by a literal in the segment, longest value first
+		for _, wildcardNode := range node.WildCardChildren {
+			if wildcardNode.Indices == "" {
+				continue
+			}
+			for end := segmentEnd - 1; end > 0; end-- {
+				if search.canonical == nil && strings.IndexByte(wildcardNode.Indices, path[end]) == -1 {
+					continue
+				}
+				foundNode, found := wildcardNode.findWildcard(path[:end], path[end:], search)
+				if found {
+					return foundNode, true
+				}
+			}
+		}
+
+		// Then wildcards spanning the whole segment
+		if segmentEnd != 0 {
+			for _, wildcardNode := range node.WildCardChildren {
+				foundNode, found := wildcardNode.findWildcard(path[:segmentEnd], path[segmentEnd:], search)
+				if found {
+					return foundNode, true
+				}
+			}
 		}
 	}
 
-	// No matching classic children: try wildcards
-	for _, wildcardNode := range node.WildCardChildren {
-		foundNode, found := wildcardNode.Find(route, currentIndex+1, routeParams) // Recursive find on wildcard node
-		if found {
-			// Populate url parameters
-			routeParams[wildcardNode.Content] = route[currentIndex]
-			return foundNode, true
+	// No matching wildcard: try catch-all, which consumes all the remaining segments
+	if node.CatchAllChild != nil && node.CatchAllChild.hasHandler() {
+		value, valid := path, true
+		if search.unescape && node.CatchAllChild.Content != mountParam {
+			// The path below mount prefixes is kept escaped
+			value, valid = unescapeParam(path)
+		}
+		if converted, match := node.CatchAllChild.matchConstraint(value); valid && match {
+			handler := node.CatchAllChild.handler(search.request)
+			if handler == nil {
+				return nil, false
+			}
+			search.handler = handler
+			*search.params = append(*search.params, routeParam{node.CatchAllChild.Content, value, converted})
+			if search.canonical != nil {
+				*search.canonical = append(*search.canonical, path...)
+			}
+			return node.CatchAllChild, true
 		}
 	}
 
 	// Not found
 	return nil, false
 }
+
+// Find the node handling the path after the wildcard value, capturing this value
+func (node *treeNode) findWildcard(value, rest string, search *nodeSearch) (*treeNode, bool) {
+	matched := value
+	if search.unescape {
+		var valid bool
+		if value, valid = unescapeParam(value); !valid {
+			return nil, false
+		}
+	}
+
+	converted, match := node.matchConstraint(value)
+	if !match {
+		return nil, false
+	}
+	foundNode, found := node.findAfter(matched, rest, search) // Recursive find on wildcard node
+	if found {
+		// Populate url parameters
+		*search.params = append(*search.params, routeParam{node.Content, value, converted})
+	}
+	return foundNode, found
+}
+
+// Search the rest of the path from this node, the matched part is kept in the canonical path when the node is found
+func (node *treeNode) findAfter(matched, rest string, search *nodeSearch) (*treeNode, bool) {
+	if search.canonical == nil {
+		return node.find(rest, search)
+	}
+
+	length := len(*search.canonical)
+	*search.canonical = append(*search.canonical, matched...)
+	foundNode, found := node.find(rest, search)
+	if !found {
+		*search.canonical = (*search.canonical)[:length]
+	}
+	return foundNode, found
+}
+
+// Compare strings of the same length ignoring the case of ASCII letters. Other bytes must be equal: edges may split
+// multi-byte characters, which can't be folded.
+func equalFoldASCII(a, b string) bool {
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if ca == cb {
+			continue
+		}
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb || ca < 'a' || ca > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Unescape a parameter value captured from an escaped path, doesn't allocate when there is nothing to unescape
+func unescapeParam(value string) (string, bool) {
+	if strings.IndexByte(value, '%') == -1 {
+		return value, true
+	}
+	unescaped, err := url.PathUnescape(value)
+	return unescaped, err == nil
+}
+
+// Length of the common prefix of a and b
+func commonPrefixLength(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -1,72 +1,423 @@
 package router
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRegister(t *testing.T) {
 	testCases := []struct {
 		method      HttpMethod
 		route       string
-		shouldPanic bool
+		expectedErr error
 	}{
 		{
-			method:      GET,
-			route:       "/",
-			shouldPanic: false,
+			method: GET,
+			route:  "/",
 		},
 		{
-			method:      GET,
-			route:       "/test",
-			shouldPanic: false,
+			method: GET,
+			route:  "/test",
 		},
 		{
 			method:      GET,
 			route:       "/test", // Already registered
-			shouldPanic: true,
+			expectedErr: ErrDuplicateRoute,
+		},
+		{
+			method: GET,
+			route:  "/test/", // Trailing slash is significant
+		},
+		{
+			method: "PROPFIND", // Non-common method
+			route:  "/",
 		},
 		{
-			method:      "UNKNOWN_METHOD",
+			method:      "PROPFIND",
+			route:       "/", // Already registered
+			expectedErr: ErrDuplicateRoute,
+		},
+		{
+			method:      "INVALID METHOD", // Not a valid HTTP token
+			route:       "/",
+			expectedErr: ErrUnsupportedMethod,
+		},
+		{
+			method:      "",
 			route:       "/",
-			shouldPanic: true,
+			expectedErr: ErrUnsupportedMethod,
 		},
 		{
 			method:      GET,
 			route:       "/{wild}/test/{wild}", // Duplicated wildcard parameter name
-			shouldPanic: true,
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method: GET,
+			route:  "/static/{filepath...}",
+		},
+		{
+			method:      GET,
+			route:       "/static/*other", // Conflicting catch-all name
+			expectedErr: ErrConflictingWildcard,
+		},
+		{
+			method: GET,
+			route:  "/proxy/*rest",
+		},
+		{
+			method:      GET,
+			route:       "/proxy/{rest...}", // Already registered
+			expectedErr: ErrDuplicateRoute,
+		},
+		{
+			method:      GET,
+			route:       "/catchall/{path...}/test", // Catch-all not in last position
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/catchall/*", // Missing catch-all name
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/{path}/{path...}", // Duplicated wildcard parameter name
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method: GET,
+			route:  "/users/{id:[0-9]+}",
+		},
+		{
+			method: GET,
+			route:  "/users/{id:[a-f]+}", // Same name with another constraint
+		},
+		{
+			method:      GET,
+			route:       "/users/{id:[0-9]+}", // Already registered
+			expectedErr: ErrDuplicateRoute,
+		},
+		{
+			method:      GET,
+			route:       "/users/{id:[0-9}", // Invalid constraint
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/users/{id:}", // Empty constraint
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/users/{id:integer}", // Unknown converter
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method: GET,
+			route:  "/langs/{lang:(en)|fr}", // Regular expression of literals
+		},
+		{
+			method:      GET,
+			route:       "/users/{:[0-9]+}", // Missing wildcard name
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method: GET,
+			route:  "/downloads/{name}.{ext}",
+		},
+		{
+			method: GET,
+			route:  "/v{version:int}/users",
+		},
+		{
+			method:      GET,
+			route:       "/partial/{a}{b}", // Adjacent wildcards
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/partial/{a", // Unclosed wildcard
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/partial/a{b...}", // Catch-all not spanning the whole segment
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/partial/{a}-{a}", // Duplicated wildcard parameter name
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method: GET,
+			route:  "/reports/{year?:int}/{month?:int}",
+		},
+		{
+			method:      GET,
+			route:       "/reports", // Already registered as optional variant
+			expectedErr: ErrDuplicateRoute,
+		},
+		{
+			method:      GET,
+			route:       "/optional/{a?}/static", // Optional parameter not trailing
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method:      GET,
+			route:       "/optional/{a?}.txt", // Optional parameter not spanning the whole segment
+			expectedErr: ErrInvalidPattern,
+		},
+		{
+			method: GET,
+			route:  "/optional/{a?}",
+		},
+		{
+			method: POST,
+			route:  "/optional",
+		},
+		{
+			method:      POST,
+			route:       "/optional/{b?}", // Conflicts with the registered route without optional parameter
+			expectedErr: ErrDuplicateRoute,
+		},
+		{
+			method: POST,
+			route:  "/optional/{b}", // Registered after the rollback of the failed registration
 		},
 	}
 
 	tree := NewTree()
 	handler := http.NotFoundHandler() // Sample handler
 	for _, tc := range testCases {
-		testFn := func() {
-			tree.Register(tc.method, tc.route, handler)
+		err := tree.Register(tc.method, tc.route, handler)
+		if tc.expectedErr == nil {
+			if err != nil {
+				t.Errorf("[%s] %s unexpected error: %v", tc.method, tc.route, err)
+			}
+			continue
 		}
-		err := checkPanic(testFn, tc.shouldPanic)
-		if err != nil {
-			t.Errorf("%v", err)
+
+		var registrationErr *RegistrationError
+		if !errors.As(err, &registrationErr) {
+			t.Errorf("[%s] %s expected registration error, got=%v", tc.method, tc.route, err)
+		} else if registrationErr.Method != tc.method || registrationErr.Route != tc.route {
+			t.Errorf("[%s] %s registration error doesn't reference the route, got=%v", tc.method, tc.route, err)
+		} else if !errors.Is(err, tc.expectedErr) {
+			t.Errorf("[%s] %s expected error=%v, got=%v", tc.method, tc.route, tc.expectedErr, err)
 		}
 	}
 }
 
 func TestFind(t *testing.T) {
 	testCases := []struct {
-		method      HttpMethod
-		url         *url.URL
-		urlParams   map[string]string
-		queryParams map[string][]string
-		expectedErr error
+		method         HttpMethod
+		url            *url.URL
+		urlParams      map[string]string
+		typedParams    map[string]any
+		queryParams    map[string][]string
+		allowedMethods []HttpMethod
+		expectedErr    error
 	}{
 		{
-			method:      "UNKNOWN_METHOD",
+			method:      "INVALID METHOD",
 			url:         getUrl("/"),
 			expectedErr: ErrUnhandledMethod,
 		},
+		{
+			method:      "UNKNOWN_METHOD", // Valid method without registered routes
+			url:         getUrl("/notfound"),
+			expectedErr: ErrNotFound,
+		},
+		{
+			method: "PROPFIND",
+			url:    getUrl("/test1/test2"),
+		},
+		{
+			method:         POST,
+			url:            getUrl("/test1/test2"), // Only registered for other methods
+			allowedMethods: []HttpMethod{PUT, "PROPFIND"},
+			expectedErr:    ErrMethodNotAllowed,
+		},
+		{
+			method:    GET,
+			url:       getUrl("/static/css/main.css"),
+			urlParams: map[string]string{"filepath": "css/main.css"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/static/css/info"), // Favor wildcard over catch-all
+			urlParams: map[string]string{"file": "css"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/static/favicon.ico"), // Favor static over catch-all
+			urlParams: map[string]string{},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/static/favicon.png"),
+			urlParams: map[string]string{"filepath": "favicon.png"},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/static"), // Catch-all matches at least one segment
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:    GET,
+			url:       getUrl("/proxy/a/b"),
+			urlParams: map[string]string{"rest": "a/b"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/users/123"),
+			urlParams: map[string]string{"id": "123"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/users/1"), // Constraint not matched: fall through to sibling wildcard
+			urlParams: map[string]string{"name": "1"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/users/abc"),
+			urlParams: map[string]string{"name": "abc"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/files/notes.txt"),
+			urlParams: map[string]string{"name": "notes.txt"},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/files/notes.md"),
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:      GET,
+			url:         getUrl("/orders/42"),
+			urlParams:   map[string]string{"id": "42"},
+			typedParams: map[string]any{"id": int64(42)},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301"),
+			urlParams:   map[string]string{"ref": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+			typedParams: map[string]any{"ref": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/orders/not-an-id"),
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:      GET,
+			url:         getUrl("/posts/hello-world"),
+			urlParams:   map[string]string{"slug": "hello-world"},
+			typedParams: map[string]any{"slug": "hello-world"},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/posts/Hello_World"),
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:      GET,
+			url:         getUrl("/reports/2024-02-29"),
+			urlParams:   map[string]string{"day": "2024-02-29"},
+			typedParams: map[string]any{"day": time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/reports/2023-02-29"),
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:      GET,
+			url:         getUrl("/skus/SKU-001"), // Custom converter
+			urlParams:   map[string]string{"sku": "SKU-001"},
+			typedParams: map[string]any{"sku": 1},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/downloads/archive.tar.gz"), // Greedy partial-segment wildcards
+			urlParams: map[string]string{"name": "archive.tar", "ext": "gz"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/downloads/report.pdf"), // Favor partial-segment wildcards over full-segment ones
+			urlParams: map[string]string{"name": "report"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/downloads/.pdf"), // Wildcards match at least one character
+			urlParams: map[string]string{"file": ".pdf"},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/v2/users"),
+			urlParams:   map[string]string{"version": "2"},
+			typedParams: map[string]any{"version": int64(2)},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/vx/users"),
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:    GET,
+			url:       getUrl("/x/ab"),
+			urlParams: map[string]string{"a": "a"},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/archives"),
+			urlParams: map[string]string{},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/archives/2024"),
+			urlParams:   map[string]string{"year": "2024"},
+			typedParams: map[string]any{"year": int64(2024)},
+		},
+		{
+			method:      GET,
+			url:         getUrl("/archives/2024/02"),
+			urlParams:   map[string]string{"year": "2024", "month": "02"},
+			typedParams: map[string]any{"year": int64(2024), "month": int64(2)},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/assets"),
+			urlParams: map[string]string{},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/assets/img/logo.png"),
+			urlParams: map[string]string{"path": "img/logo.png"},
+		},
+		{
+			method:         DELETE,
+			url:            getUrl("/test1/wildvalue1/test3/wildvalue2"),
+			allowedMethods: []HttpMethod{GET},
+			expectedErr:    ErrMethodNotAllowed,
+		},
+		{
+			method: OPTIONS,
+			url:    getUrl("/test1"),
+		},
 		{
 			method:      GET,
 			url:         getUrl("/notfound"),
@@ -98,31 +449,90 @@ func TestFind(t *testing.T) {
 				"wild2": "wildvalue2",
 			},
 		},
+		{
+			method:    GET,
+			url:       getUrl("/users/new"),
+			urlParams: map[string]string{},
+		},
+		{
+			method:    GET,
+			url:       getUrl("/users/newer"), // Static edge prefix matched: backtrack to the wildcard
+			urlParams: map[string]string{"name": "newer"},
+		},
+		{
+			method:      GET,
+			url:         getUrl("//test1//test2/test3"), // Paths are matched as is, they are cleaned by the router
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:      GET,
+			url:         getUrl("/test1/test2/test3/"), // Trailing slash is significant
+			expectedErr: ErrNotFound,
+		},
+		{
+			method:    GET,
+			url:       getUrl("/static/css/main.css/"),
+			urlParams: map[string]string{"filepath": "css/main.css/"},
+		},
 	}
 
 	handler := http.NotFoundHandler() // Sample handler
 	tree := NewTree()
-	tree.Register(GET, "/", handler)
-	tree.Register(GET, "/test1/test2/test3", handler)
-	tree.Register(GET, "/test1/{wild1}/test3/{wild2}", handler)
-	tree.Register(GET, "/test1/test2/test3/{wild2}", handler)
+	mustRegister(t, tree, GET, "/", handler)
+	mustRegister(t, tree, GET, "/test1/test2/test3", handler)
+	mustRegister(t, tree, GET, "/test1/{wild1}/test3/{wild2}", handler)
+	mustRegister(t, tree, GET, "/test1/test2/test3/{wild2}", handler)
+	mustRegister(t, tree, "PROPFIND", "/test1/test2", handler)
+	mustRegister(t, tree, OPTIONS, "/test1", handler)
+	mustRegister(t, tree, PUT, "/test1/test2", handler)
+	mustRegister(t, tree, GET, "/static/{filepath...}", handler)
+	mustRegister(t, tree, GET, "/static/{file}/info", handler)
+	mustRegister(t, tree, GET, "/static/favicon.ico", handler)
+	mustRegister(t, tree, GET, "/proxy/*rest", handler)
+	mustRegister(t, tree, GET, "/users/{name}", handler)
+	mustRegister(t, tree, GET, "/users/new", handler)
+	mustRegister(t, tree, GET, "/users/{id:[0-9]{2,}}", handler) // Favored over the unconstrained wildcard
+	mustRegister(t, tree, GET, "/files/{name:[a-z]+\\.txt}", handler)
+	mustRegister(t, tree, GET, "/orders/{id:int}", handler)
+	mustRegister(t, tree, GET, "/orders/{ref:uuid}", handler)
+	mustRegister(t, tree, GET, "/posts/{slug:slug}", handler)
+	mustRegister(t, tree, GET, "/reports/{day:date}", handler)
+	tree.RegisterConverter("sku", func(value string) (any, error) {
+		number, found := strings.CutPrefix(value, "SKU-")
+		if !found {
+			return nil, errors.New("invalid sku")
+		}
+		return strconv.Atoi(number)
+	})
+	mustRegister(t, tree, GET, "/skus/{sku:sku}", handler)
+	mustRegister(t, tree, GET, "/downloads/{file}", handler)
+	mustRegister(t, tree, GET, "/downloads/{name}.pdf", handler)
+	mustRegister(t, tree, GET, "/downloads/{name}.{ext}", handler)
+	mustRegister(t, tree, GET, "/v{version:int}/users", handler)
+	mustRegister(t, tree, GET, "/x/{a}b", handler)
+	mustRegister(t, tree, GET, "/archives/{year?:int}/{month?:int}", handler)
+	mustRegister(t, tree, GET, "/assets/{path?...}", handler)
 
 	for _, tc := range testCases {
-		routeData, err := tree.Find(tc.method, tc.url)
+		rCtx := &requestContext{Context: context.Background(), rawQuery: tc.url.RawQuery}
+		routeData, err := tree.Find(tc.method, tc.url.Path, rCtx)
+		routeParams, typedParams := paramsMaps(rCtx)
 		if tc.expectedErr != nil {
 			if err == nil {
 				t.Errorf("expected error=%v, got none", tc.expectedErr)
 			} else if !errors.Is(err, tc.expectedErr) {
 				t.Errorf("expected error=%v, got=%v", tc.expectedErr, err)
+			} else if !slices.Equal(tc.allowedMethods, routeData.AllowedMethods) {
+				t.Errorf("got wrong allowed methods. expected=%v, got=%v", tc.allowedMethods, routeData.AllowedMethods)
 			}
 		} else if err != nil {
 			t.Errorf("expected error: %v", err)
 		} else {
 			if tc.urlParams != nil {
-				if len(tc.urlParams) != len(routeData.Context.RouteParams) {
-					t.Errorf("got wrong url parameters count. expected=%v, got=%v", len(tc.urlParams), len(routeData.Context.RouteParams))
+				if len(tc.urlParams) != len(routeParams) {
+					t.Errorf("got wrong url parameters count. expected=%v, got=%v", len(tc.urlParams), len(routeParams))
 				}
-				for paramKey, paramVal := range routeData.Context.RouteParams {
+				for paramKey, paramVal := range routeParams {
 					if _, found := tc.urlParams[paramKey]; !found {
 						t.Errorf("url parameter not found: %s", paramKey)
 					} else if paramVal != tc.urlParams[paramKey] {
@@ -130,11 +540,23 @@ func TestFind(t *testing.T) {
 					}
 				}
 			}
+			if tc.typedParams != nil {
+				if len(tc.typedParams) != len(typedParams) {
+					t.Errorf("got wrong typed parameters count. expected=%v, got=%v", len(tc.typedParams), len(typedParams))
+				}
+				for paramKey, paramVal := range typedParams {
+					if expected, found := tc.typedParams[paramKey]; !found {
+						t.Errorf("typed parameter not found: %s", paramKey)
+					} else if paramVal != expected {
+						t.Errorf("typed parameter doesn't match the expected (%s). expected=%v, got=%v", paramKey, expected, paramVal)
+					}
+				}
+			}
 			if tc.queryParams != nil {
-				if len(tc.queryParams) != len(routeData.Context.QueryParams) {
-					t.Errorf("got wrong query parameters count. expected=%v, got=%v", len(tc.queryParams), len(routeData.Context.QueryParams))
+				if len(tc.queryParams) != len(rCtx.queryParams()) {
+					t.Errorf("got wrong query parameters count. expected=%v, got=%v", len(tc.queryParams), len(rCtx.queryParams()))
 				}
-				for paramKey, paramVal := range routeData.Context.QueryParams {
+				for paramKey, paramVal := range rCtx.queryParams() {
 					if _, found := tc.queryParams[paramKey]; !found {
 						t.Errorf("query parameter not found: %s", paramKey)
 					} else if len(paramVal) != len(tc.queryParams[paramKey]) {
@@ -152,6 +574,158 @@ func TestFind(t *testing.T) {
 	}
 }
 
+func TestFindWithPredicates(t *testing.T) {
+	testCases := []struct {
+		route           string
+		header          http.Header
+		expectedErr     error
+		expectedHandler string
+	}{
+		{
+			route:           "/items",
+			header:          http.Header{"Accept": {"text/html, application/vnd.api.v2+json;q=0.9"}},
+			expectedHandler: "v2",
+		},
+		{
+			route:           "/items",
+			header:          http.Header{"Accept": {"application/vnd.api.v2+json"}, "X-Api-Version": {"3"}},
+			expectedHandler: "v2", // Registration order
+		},
+		{
+			route:           "/items",
+			header:          http.Header{"X-Api-Version": {"3"}},
+			expectedHandler: "v3",
+		},
+		{
+			route:           "/items",
+			header:          http.Header{"Accept": {"*/*"}},
+			expectedHandler: "default",
+		},
+		{
+			route:           "/files/report.pdf?download",
+			expectedHandler: "download",
+		},
+		{
+			route:           "/files/report.pdf", // Backtrack to the catch-all
+			expectedHandler: "files",
+		},
+		{
+			route:       "/exports?format=csv",
+			expectedErr: ErrNotFound,
+		},
+		{
+			route:           "/exports?format=csv&async",
+			expectedHandler: "async export",
+		},
+	}
+
+	namedHandler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	tree := NewTree()
+	mustRegister(t, tree, GET, "/items", namedHandler("default"))
+	if err := tree.Register(GET, "/items", namedHandler("v2"), MatchAccept("application/vnd.api.v2+json")); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	if err := tree.Register(GET, "/items", namedHandler("v3"), MatchHeader("x-api-version", "3")); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	if err := tree.Register(GET, "/files/{name}", namedHandler("download"), MatchQuery("download")); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	mustRegister(t, tree, GET, "/files/{path...}", namedHandler("files"))
+	if err := tree.Register(GET, "/exports", namedHandler("async export"), MatchQuery("async")); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(string(GET), "https://127.0.0.1"+tc.route, nil)
+		req.Header = tc.header
+		rCtx := acquireRequestContext(req)
+		routeData, err := tree.Find(GET, req.URL.Path, rCtx)
+		releaseRequestContext(rCtx)
+
+		if !errors.Is(err, tc.expectedErr) {
+			t.Errorf("%s expected error=%v, got=%v", tc.route, tc.expectedErr, err)
+			continue
+		}
+		if tc.expectedErr != nil {
+			continue
+		}
+		w := httptest.NewRecorder()
+		routeData.Handler.ServeHTTP(w, req)
+		if w.Body.String() != tc.expectedHandler {
+			t.Errorf("%s got unexpected handler. expected=%s, got=%s", tc.route, tc.expectedHandler, w.Body.String())
+		}
+	}
+}
+
+func TestRegisterPredicatesConflict(t *testing.T) {
+	handler := http.NotFoundHandler() // Sample handler
+	tree := NewTree()
+	mustRegister(t, tree, GET, "/items", handler)
+	if err := tree.Register(GET, "/items", handler, MatchHeader("X-Api-Version", "2"), MatchQuery("debug")); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	if err := tree.Register(GET, "/items", handler, MatchHeader("X-Api-Version", "3")); err != nil {
+		t.Errorf("unexpected registration error: %v", err)
+	}
+
+	// Same predicates, in another order
+	err := tree.Register(GET, "/items", handler, MatchQuery("debug"), MatchHeader("x-api-version", "2"))
+	if !errors.Is(err, ErrDuplicateRoute) {
+		t.Errorf("expected duplicate route error, got=%v", err)
+	}
+
+	// The variants registered before a conflict are rolled back
+	if err := tree.Register(GET, "/reports/{year}", handler, MatchQuery("csv")); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	err = tree.Register(GET, "/reports/{year?}", handler, MatchQuery("csv"))
+	if !errors.Is(err, ErrDuplicateRoute) {
+		t.Errorf("expected duplicate route error, got=%v", err)
+	}
+	if err := tree.Register(GET, "/reports", handler, MatchQuery("csv")); err != nil {
+		t.Errorf("unexpected registration error after rollback: %v", err)
+	}
+}
+
+func TestFindAllocations(t *testing.T) {
+	tree := githubTree(t)
+	rCtx := &requestContext{params: make([]routeParam, 0, paramsCapacity)}
+	for _, path := range []string{"/user/repos", "/repos/valsov/router/stargazers", "/repos/valsov/router/contents/docs/README.md"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			rCtx.params = rCtx.params[:0]
+			if _, err := tree.Find(GET, path, rCtx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s got unexpected allocations. expected=0, got=%v", path, allocs)
+		}
+	}
+}
+
+func paramsMaps(rCtx *requestContext) (map[string]string, map[string]any) {
+	routeParams := map[string]string{}
+	typedParams := map[string]any{}
+	for _, p := range rCtx.params {
+		routeParams[p.name] = p.value
+		if p.converted != nil {
+			typedParams[p.name] = p.converted
+		}
+	}
+	return routeParams, typedParams
+}
+
+func mustRegister(t *testing.T, tree *tree, method HttpMethod, route string, handler http.Handler) {
+	if err := tree.Register(method, route, handler); err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+}
+
 func getUrl(route string) *url.URL {
 	result, _ := url.ParseRequestURI(fmt.Sprintf("https://127.0.0.1%s", route))
 	return result
@@ -175,3 +749,294 @@ func checkPanic(testFn func(), shouldPanic bool) (err error) {
 	testFn()
 	return err
 }
+
+// GitHub REST API (v3) routes
+var githubRoutes = []struct {
+	method HttpMethod
+	route  string
+}{
+	// OAuth Authorizations
+	{GET, "/authorizations"},
+	{GET, "/authorizations/{id}"},
+	{POST, "/authorizations"},
+	{DELETE, "/authorizations/{id}"},
+	{GET, "/applications/{client_id}/tokens/{access_token}"},
+	{DELETE, "/applications/{client_id}/tokens"},
+	{DELETE, "/applications/{client_id}/tokens/{access_token}"},
+
+	// Activity
+	{GET, "/events"},
+	{GET, "/repos/{owner}/{repo}/events"},
+	{GET, "/networks/{owner}/{repo}/events"},
+	{GET, "/orgs/{org}/events"},
+	{GET, "/users/{user}/received_events"},
+	{GET, "/users/{user}/received_events/public"},
+	{GET, "/users/{user}/events"},
+	{GET, "/users/{user}/events/public"},
+	{GET, "/users/{user}/events/orgs/{org}"},
+	{GET, "/feeds"},
+	{GET, "/notifications"},
+	{GET, "/repos/{owner}/{repo}/notifications"},
+	{PUT, "/notifications"},
+	{PUT, "/repos/{owner}/{repo}/notifications"},
+	{GET, "/notifications/threads/{id}"},
+	{GET, "/notifications/threads/{id}/subscription"},
+	{PUT, "/notifications/threads/{id}/subscription"},
+	{DELETE, "/notifications/threads/{id}/subscription"},
+	{GET, "/repos/{owner}/{repo}/stargazers"},
+	{GET, "/users/{user}/starred"},
+	{GET, "/user/starred"},
+	{GET, "/user/starred/{owner}/{repo}"},
+	{PUT, "/user/starred/{owner}/{repo}"},
+	{DELETE, "/user/starred/{owner}/{repo}"},
+	{GET, "/repos/{owner}/{repo}/subscribers"},
+	{GET, "/users/{user}/subscriptions"},
+	{GET, "/user/subscriptions"},
+	{GET, "/repos/{owner}/{repo}/subscription"},
+	{PUT, "/repos/{owner}/{repo}/subscription"},
+	{DELETE, "/repos/{owner}/{repo}/subscription"},
+	{GET, "/user/subscriptions/{owner}/{repo}"},
+	{PUT, "/user/subscriptions/{owner}/{repo}"},
+	{DELETE, "/user/subscriptions/{owner}/{repo}"},
+
+	// Gists
+	{GET, "/users/{user}/gists"},
+	{GET, "/gists"},
+	{GET, "/gists/{id}"},
+	{POST, "/gists"},
+	{PUT, "/gists/{id}/star"},
+	{DELETE, "/gists/{id}/star"},
+	{GET, "/gists/{id}/star"},
+	{POST, "/gists/{id}/forks"},
+	{DELETE, "/gists/{id}"},
+
+	// Git Data
+	{GET, "/repos/{owner}/{repo}/git/blobs/{sha}"},
+	{POST, "/repos/{owner}/{repo}/git/blobs"},
+	{GET, "/repos/{owner}/{repo}/git/commits/{sha}"},
+	{POST, "/repos/{owner}/{repo}/git/commits"},
+	{GET, "/repos/{owner}/{repo}/git/refs"},
+	{POST, "/repos/{owner}/{repo}/git/refs"},
+	{GET, "/repos/{owner}/{repo}/git/tags/{sha}"},
+	{POST, "/repos/{owner}/{repo}/git/tags"},
+	{GET, "/repos/{owner}/{repo}/git/trees/{sha}"},
+	{POST, "/repos/{owner}/{repo}/git/trees"},
+
+	// Issues
+	{GET, "/issues"},
+	{GET, "/user/issues"},
+	{GET, "/orgs/{org}/issues"},
+	{GET, "/repos/{owner}/{repo}/issues"},
+	{GET, "/repos/{owner}/{repo}/issues/{number}"},
+	{POST, "/repos/{owner}/{repo}/issues"},
+	{GET, "/repos/{owner}/{repo}/assignees"},
+	{GET, "/repos/{owner}/{repo}/assignees/{assignee}"},
+	{GET, "/repos/{owner}/{repo}/issues/{number}/comments"},
+	{POST, "/repos/{owner}/{repo}/issues/{number}/comments"},
+	{GET, "/repos/{owner}/{repo}/issues/{number}/events"},
+	{GET, "/repos/{owner}/{repo}/labels"},
+	{GET, "/repos/{owner}/{repo}/labels/{name}"},
+	{POST, "/repos/{owner}/{repo}/labels"},
+	{DELETE, "/repos/{owner}/{repo}/labels/{name}"},
+	{GET, "/repos/{owner}/{repo}/issues/{number}/labels"},
+	{POST, "/repos/{owner}/{repo}/issues/{number}/labels"},
+	{DELETE, "/repos/{owner}/{repo}/issues/{number}/labels/{name}"},
+	{PUT, "/repos/{owner}/{repo}/issues/{number}/labels"},
+	{DELETE, "/repos/{owner}/{repo}/issues/{number}/labels"},
+	{GET, "/repos/{owner}/{repo}/milestones/{number}/labels"},
+	{GET, "/repos/{owner}/{repo}/milestones"},
+	{GET, "/repos/{owner}/{repo}/milestones/{number}"},
+	{POST, "/repos/{owner}/{repo}/milestones"},
+	{DELETE, "/repos/{owner}/{repo}/milestones/{number}"},
+
+	// Miscellaneous
+	{GET, "/emojis"},
+	{GET, "/gitignore/templates"},
+	{GET, "/gitignore/templates/{name}"},
+	{POST, "/markdown"},
+	{POST, "/markdown/raw"},
+	{GET, "/meta"},
+	{GET, "/rate_limit"},
+
+	// Organizations
+	{GET, "/users/{user}/orgs"},
+	{GET, "/user/orgs"},
+	{GET, "/orgs/{org}"},
+	{GET, "/orgs/{org}/members"},
+	{GET, "/orgs/{org}/members/{user}"},
+	{DELETE, "/orgs/{org}/members/{user}"},
+	{GET, "/orgs/{org}/public_members"},
+	{GET, "/orgs/{org}/public_members/{user}"},
+	{PUT, "/orgs/{org}/public_members/{user}"},
+	{DELETE, "/orgs/{org}/public_members/{user}"},
+	{GET, "/orgs/{org}/teams"},
+	{GET, "/teams/{id}"},
+	{POST, "/orgs/{org}/teams"},
+	{DELETE, "/teams/{id}"},
+	{GET, "/teams/{id}/members"},
+	{GET, "/teams/{id}/members/{user}"},
+	{PUT, "/teams/{id}/members/{user}"},
+	{DELETE, "/teams/{id}/members/{user}"},
+	{GET, "/teams/{id}/repos"},
+	{GET, "/teams/{id}/repos/{owner}/{repo}"},
+	{PUT, "/teams/{id}/repos/{owner}/{repo}"},
+	{DELETE, "/teams/{id}/repos/{owner}/{repo}"},
+	{GET, "/user/teams"},
+
+	// Pull Requests
+	{GET, "/repos/{owner}/{repo}/pulls"},
+	{GET, "/repos/{owner}/{repo}/pulls/{number}"},
+	{POST, "/repos/{owner}/{repo}/pulls"},
+	{GET, "/repos/{owner}/{repo}/pulls/{number}/commits"},
+	{GET, "/repos/{owner}/{repo}/pulls/{number}/files"},
+	{GET, "/repos/{owner}/{repo}/pulls/{number}/merge"},
+	{PUT, "/repos/{owner}/{repo}/pulls/{number}/merge"},
+	{GET, "/repos/{owner}/{repo}/pulls/{number}/comments"},
+	{PUT, "/repos/{owner}/{repo}/pulls/{number}/comments"},
+
+	// Repositories
+	{GET, "/user/repos"},
+	{GET, "/users/{user}/repos"},
+	{GET, "/orgs/{org}/repos"},
+	{GET, "/repositories"},
+	{POST, "/user/repos"},
+	{POST, "/orgs/{org}/repos"},
+	{GET, "/repos/{owner}/{repo}"},
+	{GET, "/repos/{owner}/{repo}/contributors"},
+	{GET, "/repos/{owner}/{repo}/languages"},
+	{GET, "/repos/{owner}/{repo}/teams"},
+	{GET, "/repos/{owner}/{repo}/tags"},
+	{GET, "/repos/{owner}/{repo}/branches"},
+	{GET, "/repos/{owner}/{repo}/branches/{branch}"},
+	{DELETE, "/repos/{owner}/{repo}"},
+	{GET, "/repos/{owner}/{repo}/collaborators"},
+	{GET, "/repos/{owner}/{repo}/collaborators/{user}"},
+	{PUT, "/repos/{owner}/{repo}/collaborators/{user}"},
+	{DELETE, "/repos/{owner}/{repo}/collaborators/{user}"},
+	{GET, "/repos/{owner}/{repo}/comments"},
+	{GET, "/repos/{owner}/{repo}/commits/{sha}/comments"},
+	{POST, "/repos/{owner}/{repo}/commits/{sha}/comments"},
+	{GET, "/repos/{owner}/{repo}/comments/{id}"},
+	{DELETE, "/repos/{owner}/{repo}/comments/{id}"},
+	{GET, "/repos/{owner}/{repo}/commits"},
+	{GET, "/repos/{owner}/{repo}/commits/{sha}"},
+	{GET, "/repos/{owner}/{repo}/readme"},
+	{GET, "/repos/{owner}/{repo}/contents/{path...}"},
+	{DELETE, "/repos/{owner}/{repo}/contents/{path...}"},
+	{GET, "/repos/{owner}/{repo}/{archive_format}/{ref}"},
+	{GET, "/repos/{owner}/{repo}/keys"},
+	{GET, "/repos/{owner}/{repo}/keys/{id}"},
+	{POST, "/repos/{owner}/{repo}/keys"},
+	{DELETE, "/repos/{owner}/{repo}/keys/{id}"},
+	{GET, "/repos/{owner}/{repo}/downloads"},
+	{GET, "/repos/{owner}/{repo}/downloads/{id}"},
+	{DELETE, "/repos/{owner}/{repo}/downloads/{id}"},
+	{GET, "/repos/{owner}/{repo}/forks"},
+	{POST, "/repos/{owner}/{repo}/forks"},
+	{GET, "/repos/{owner}/{repo}/hooks"},
+	{GET, "/repos/{owner}/{repo}/hooks/{id}"},
+	{POST, "/repos/{owner}/{repo}/hooks"},
+	{POST, "/repos/{owner}/{repo}/hooks/{id}/tests"},
+	{DELETE, "/repos/{owner}/{repo}/hooks/{id}"},
+	{POST, "/repos/{owner}/{repo}/merges"},
+	{GET, "/repos/{owner}/{repo}/releases"},
+	{GET, "/repos/{owner}/{repo}/releases/{id}"},
+	{POST, "/repos/{owner}/{repo}/releases"},
+	{DELETE, "/repos/{owner}/{repo}/releases/{id}"},
+	{GET, "/repos/{owner}/{repo}/releases/{id}/assets"},
+	{GET, "/repos/{owner}/{repo}/stats/contributors"},
+	{GET, "/repos/{owner}/{repo}/stats/commit_activity"},
+	{GET, "/repos/{owner}/{repo}/stats/code_frequency"},
+	{GET, "/repos/{owner}/{repo}/stats/participation"},
+	{GET, "/repos/{owner}/{repo}/stats/punch_card"},
+	{GET, "/repos/{owner}/{repo}/statuses/{ref}"},
+	{POST, "/repos/{owner}/{repo}/statuses/{ref}"},
+
+	// Search
+	{GET, "/search/repositories"},
+	{GET, "/search/code"},
+	{GET, "/search/issues"},
+	{GET, "/search/users"},
+	{GET, "/legacy/issues/search/{owner}/{repository}/{state}/{keyword}"},
+	{GET, "/legacy/repos/search/{keyword}"},
+	{GET, "/legacy/user/search/{keyword}"},
+	{GET, "/legacy/user/email/{email}"},
+
+	// Users
+	{GET, "/users/{user}"},
+	{GET, "/user"},
+	{GET, "/users"},
+	{GET, "/user/emails"},
+	{POST, "/user/emails"},
+	{DELETE, "/user/emails"},
+	{GET, "/users/{user}/followers"},
+	{GET, "/user/followers"},
+	{GET, "/users/{user}/following"},
+	{GET, "/user/following"},
+	{GET, "/user/following/{user}"},
+	{GET, "/users/{user}/following/{target_user}"},
+	{PUT, "/user/following/{user}"},
+	{DELETE, "/user/following/{user}"},
+	{GET, "/users/{user}/keys"},
+	{GET, "/user/keys"},
+	{GET, "/user/keys/{id}"},
+	{POST, "/user/keys"},
+	{DELETE, "/user/keys/{id}"},
+}
+
+// Replace the wildcards of the route by sample values
+var wildcardRegexp = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+func githubTree(b testing.TB) *tree {
+	tree := NewTree()
+	for _, r := range githubRoutes {
+		if err := tree.Register(r.method, r.route, http.NotFoundHandler()); err != nil {
+			b.Fatalf("unexpected registration error: %v", err)
+		}
+	}
+	return tree
+}
+
+func benchmarkFind(b *testing.B, tree *tree, method HttpMethod, path string) {
+	rCtx := &requestContext{params: make([]routeParam, 0, paramsCapacity)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rCtx.params = rCtx.params[:0]
+		if _, err := tree.Find(method, path, rCtx); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkGithubStatic(b *testing.B) {
+	benchmarkFind(b, githubTree(b), GET, "/user/repos")
+}
+
+func BenchmarkGithubParam(b *testing.B) {
+	benchmarkFind(b, githubTree(b), GET, "/repos/valsov/router/stargazers")
+}
+
+func BenchmarkGithubCatchAll(b *testing.B) {
+	benchmarkFind(b, githubTree(b), GET, "/repos/valsov/router/contents/docs/README.md")
+}
+
+func BenchmarkGithubAll(b *testing.B) {
+	tree := githubTree(b)
+	paths := make([]string, len(githubRoutes))
+	for i, r := range githubRoutes {
+		paths[i] = wildcardRegexp.ReplaceAllString(r.route, "$1")
+	}
+
+	rCtx := &requestContext{params: make([]routeParam, 0, paramsCapacity)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, r := range githubRoutes {
+			rCtx.params = rCtx.params[:0]
+			if _, err := tree.Find(r.method, paths[j], rCtx); err != nil {
+				b.Fatalf("[%s] %s unexpected error: %v", r.method, paths[j], err)
+			}
+		}
+	}
+}